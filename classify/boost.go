@@ -0,0 +1,325 @@
+package classify
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+const (
+	// boostDepth is the depth of boosted trees when MaxDepth is 0
+	boostDepth = 3
+	// boostRate is the learning rate when LearningRate is 0
+	boostRate = 0.1
+	// boostValidation is the fraction of rows held out for early stopping
+	boostValidation = 0.1
+	// boosting stops after boostPatience rounds without a lower validation loss
+	boostPatience = 10
+	// boostLambda regularizes leaf values
+	boostLambda = 1.0
+	// boostMinLeaf is the min number of rows in a leaf
+	boostMinLeaf = 5
+)
+
+// Boosted is a gradient-boosted ensemble of regression trees fit to the
+// log-loss. The spam probability of a row is the sigmoid of Base plus the
+// sum of its leaf values, which is calibrated on the training data.
+type Boosted struct {
+	// Features are the dataset columns the trees split on
+	Features []string
+	// Base is the log-odds of spam in the training data
+	Base  float64
+	Trees []BoostTree
+	// Gain is the total loss reduction of the splits on each feature
+	Gain []float64
+}
+
+// BoostTree is a regression tree. Nodes[0] is the root.
+type BoostTree struct {
+	Nodes []BoostNode
+}
+
+// BoostNode is a split or a leaf of a regression tree. Rows with a
+// feature at most the threshold go left.
+type BoostNode struct {
+	Leaf      bool
+	Value     float64
+	Feature   int
+	Threshold float64
+	Left      int
+	Right     int
+}
+
+func (t BoostTree) value(row []float64) float64 {
+	node := t.Nodes[0]
+	for !node.Leaf {
+		if row[node.Feature] <= node.Threshold {
+			node = t.Nodes[node.Left]
+		} else {
+			node = t.Nodes[node.Right]
+		}
+	}
+	return node.Value
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// HoldOutValidation splits off the rows boosting uses to stop early. Call it
+// before weighting and rebalancing the rest, so the validation rows keep the
+// dataset's class balance and none of them are repeated in training. valid
+// is nil for other classifiers, or if there are too few rows to hold out.
+func HoldOutValidation(d *Dataset, p Params, rng *rand.Rand) (train, valid *Dataset) {
+	if p.Classifier != ClassifierBoosting {
+		return d, nil
+	}
+	train, valid = Split(d, boostValidation, rng)
+	if nonSpam, spam := valid.ClassCounts(); nonSpam == 0 || spam == 0 {
+		// too few rows to hold out, so train on all of them
+		return d, nil
+	}
+	return train, valid
+}
+
+// FitBoosted trains gradient-boosted trees on a dataset. It adds up to
+// p.Trees trees, stopping early once the log-loss on valid stops improving,
+// or adds all of them if valid is nil.
+func FitBoosted(train, valid *Dataset, p Params) (*Boosted, error) {
+	if err := p.Validate(train.Cols); err != nil {
+		return nil, err
+	}
+	depth, rate := p.MaxDepth, p.LearningRate
+	if depth == 0 {
+		depth = boostDepth
+	}
+	if rate == 0 {
+		rate = boostRate
+	}
+
+	nonSpam, spam := train.ClassCounts()
+	if nonSpam == 0 || spam == 0 {
+		return nil, fmt.Errorf("Need rows of both classes to train")
+	}
+
+	nFeat := len(train.Cols) - 1
+	b := &Boosted{
+		Features: train.Cols[:nFeat],
+		Base:     math.Log(float64(spam) / float64(nonSpam)),
+		Gain:     make([]float64, nFeat),
+	}
+
+	// sort rows by each feature once; trees split these orders
+	order := make([][]int, nFeat)
+	for f := range order {
+		order[f] = make([]int, len(train.Rows))
+		for i := range order[f] {
+			order[f][i] = i
+		}
+		sort.SliceStable(order[f], func(i, j int) bool {
+			return train.Rows[order[f][i]][f] < train.Rows[order[f][j]][f]
+		})
+	}
+
+	score := make([]float64, len(train.Rows))
+	for i := range score {
+		score[i] = b.Base
+	}
+	var validScore, validProbs []float64
+	if valid != nil {
+		validScore = make([]float64, len(valid.Rows))
+		validProbs = make([]float64, len(valid.Rows))
+		for i := range validScore {
+			validScore[i] = b.Base
+		}
+	}
+
+	grad, hess := make([]float64, len(train.Rows)), make([]float64, len(train.Rows))
+	bestLoss, bestTrees := math.Inf(1), 0
+	gains := [][]float64{}
+	for round := 0; round < p.Trees; round++ {
+		for i := range train.Rows {
+			prob := sigmoid(score[i])
+			grad[i] = float64(train.Label(i)) - prob
+			hess[i] = math.Max(prob*(1-prob), 1e-12)
+		}
+
+		gain := make([]float64, nFeat)
+		tree := BoostTree{}
+		inNode := make([]bool, len(train.Rows))
+		for i := range inNode {
+			inNode[i] = true
+		}
+		growBoostNode(&tree, train, order, inNode, grad, hess, depth, rate, gain)
+		b.Trees = append(b.Trees, tree)
+		gains = append(gains, gain)
+
+		for i, row := range train.Rows {
+			score[i] += tree.value(row)
+		}
+		if valid == nil {
+			continue
+		}
+		for i, row := range valid.Rows {
+			validScore[i] += tree.value(row)
+		}
+		for i, score := range validScore {
+			validProbs[i] = sigmoid(score)
+		}
+		if loss := LogLoss(valid, validProbs); loss < bestLoss {
+			bestLoss, bestTrees = loss, len(b.Trees)
+		} else if len(b.Trees)-bestTrees >= boostPatience {
+			break
+		}
+	}
+	if valid != nil {
+		b.Trees, gains = b.Trees[:bestTrees], gains[:bestTrees]
+	}
+	for _, gain := range gains {
+		for f, g := range gain {
+			b.Gain[f] += g
+		}
+	}
+	return b, nil
+}
+
+// growBoostNode appends a node fit to the gradients of the rows in inNode,
+// and its subtree, returning its index
+func growBoostNode(tree *BoostTree, d *Dataset, order [][]int, inNode []bool, grad, hess []float64, depth int, rate float64, gain []float64) int {
+	i := len(tree.Nodes)
+	g, h, n := 0.0, 0.0, 0
+	for r, ok := range inNode {
+		if ok {
+			g, h, n = g+grad[r], h+hess[r], n+1
+		}
+	}
+	tree.Nodes = append(tree.Nodes, BoostNode{Leaf: true, Value: rate * g / (h + boostLambda)})
+	if depth == 0 || n < 2*boostMinLeaf {
+		return i
+	}
+
+	// find the split with the largest reduction in loss
+	parent := g * g / (h + boostLambda)
+	bestGain, bestFeat, bestThresh := 0.0, -1, 0.0
+	for f, rows := range order {
+		gl, hl, nl := 0.0, 0.0, 0
+		prev := -1
+		for _, r := range rows {
+			if !inNode[r] {
+				continue
+			}
+			if prev >= 0 && nl >= boostMinLeaf && n-nl >= boostMinLeaf && d.Rows[r][f] > d.Rows[prev][f] {
+				gr, hr := g-gl, h-hl
+				if split := gl*gl/(hl+boostLambda) + gr*gr/(hr+boostLambda) - parent; split > bestGain {
+					bestGain, bestFeat = split, f
+					bestThresh = (d.Rows[prev][f] + d.Rows[r][f]) / 2
+				}
+			}
+			gl, hl, nl = gl+grad[r], hl+hess[r], nl+1
+			prev = r
+		}
+	}
+	if bestFeat < 0 {
+		return i
+	}
+	gain[bestFeat] += bestGain
+
+	left, right := make([]bool, len(inNode)), make([]bool, len(inNode))
+	for r, ok := range inNode {
+		if !ok {
+			continue
+		}
+		if d.Rows[r][bestFeat] <= bestThresh {
+			left[r] = true
+		} else {
+			right[r] = true
+		}
+	}
+	l := growBoostNode(tree, d, order, left, grad, hess, depth-1, rate, gain)
+	rt := growBoostNode(tree, d, order, right, grad, hess, depth-1, rate, gain)
+	tree.Nodes[i] = BoostNode{Feature: bestFeat, Threshold: bestThresh, Left: l, Right: rt}
+	return i
+}
+
+// LogLoss is the mean log-loss of spam probabilities
+func LogLoss(d *Dataset, probs []float64) float64 {
+	total := 0.0
+	for i, prob := range probs {
+		prob = math.Min(math.Max(prob, 1e-15), 1-1e-15)
+		if d.Label(i) == 1 {
+			total -= math.Log(prob)
+		} else {
+			total -= math.Log(1 - prob)
+		}
+	}
+	return total / float64(len(probs))
+}
+
+// Proba returns the spam probability of each row of a dataset. The
+// dataset must have the model's features.
+func (b *Boosted) Proba(d *Dataset) ([]float64, error) {
+	cols, err := featureIndex(b.Features, d.Cols)
+	if err != nil {
+		return nil, err
+	}
+	probs := make([]float64, len(d.Rows))
+	row := make([]float64, len(cols))
+	for r, values := range d.Rows {
+		for i, c := range cols {
+			row[i] = values[c]
+		}
+		score := b.Base
+		for _, tree := range b.Trees {
+			score += tree.value(row)
+		}
+		probs[r] = sigmoid(score)
+	}
+	return probs, nil
+}
+
+// Importance returns each feature's share of the total gain of the splits
+func (b *Boosted) Importance() map[string]float64 {
+	total := 0.0
+	for _, g := range b.Gain {
+		total += g
+	}
+	imp := map[string]float64{}
+	for f, name := range b.Features {
+		if total > 0 {
+			imp[name] = b.Gain[f] / total
+		} else {
+			imp[name] = 0
+		}
+	}
+	return imp
+}
+
+// Save writes the model to a gob file
+func (b *Boosted) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(b); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadBoosted reads a model saved by Boosted.Save
+func LoadBoosted(path string) (*Boosted, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var b Boosted
+	if err := gob.NewDecoder(file).Decode(&b); err != nil {
+		return nil, fmt.Errorf("Invalid boosted model %s: %s", path, err)
+	}
+	return &b, nil
+}
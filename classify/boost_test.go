@@ -0,0 +1,56 @@
+package classify
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestFitBoosted(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	train, test := syntheticDataset(400, rng), syntheticDataset(200, rng)
+
+	p := Params{Classifier: ClassifierBoosting, Trees: 500}
+	fit, valid := HoldOutValidation(train, p, rng)
+	if _, spam := valid.ClassCounts(); len(fit.Rows)+len(valid.Rows) != len(train.Rows) || spam == 0 {
+		t.Fatalf("held out %s from %d rows", valid.Balance(), len(train.Rows))
+	}
+	b, err := FitBoosted(fit, valid, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Trees) == 0 || len(b.Trees) == 500 {
+		t.Errorf("got %d trees, want early stopping before 500", len(b.Trees))
+	}
+
+	probs, err := b.Proba(test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f1 := F1(test, probs, 0.5); f1 < 0.8 {
+		t.Errorf("got test F1 %.3f, want at least 0.8", f1)
+	}
+
+	imp := b.Importance()
+	if imp["age"] < imp["followers"] || imp["age"] < imp["body_len"] {
+		t.Errorf("got importance %v, want age above the noise features", imp)
+	}
+
+	path := filepath.Join(t.TempDir(), "boosted.gob")
+	if err := b.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBoosted(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Proba(test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range probs {
+		if got[i] != probs[i] {
+			t.Fatalf("row %d: got probability %v after loading, want %v", i, got[i], probs[i])
+		}
+	}
+}
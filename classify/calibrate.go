@@ -0,0 +1,175 @@
+package classify
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Calibration methods
+const (
+	CalibrationNone = "none"
+	// CalibrationPlatt fits a sigmoid to the scores
+	CalibrationPlatt = "platt"
+	// CalibrationIsotonic fits a non-decreasing step function to the scores
+	CalibrationIsotonic = "isotonic"
+)
+
+// HoldOut is the fraction of each class held out of training to calibrate
+// scores and choose a threshold on
+const HoldOut = 0.2
+
+// Calibration maps a model's spam scores to the observed fraction of spam
+// among held-out rows with that score
+type Calibration struct {
+	Method string `json:"method"`
+	// A and B are the Platt sigmoid 1/(1+exp(A*score+B))
+	A float64 `json:"a,omitempty"`
+	B float64 `json:"b,omitempty"`
+	// X and Y are the isotonic fit's increasing scores and their
+	// probabilities, interpolated between
+	X []float64 `json:"x,omitempty"`
+	Y []float64 `json:"y,omitempty"`
+	// Rows is the number of held-out rows it was fit on
+	Rows int `json:"rows"`
+}
+
+// Calibrate fits a calibration of the scores probs of a held-out dataset
+func Calibrate(method string, d *Dataset, probs []float64) (*Calibration, error) {
+	nonSpam, spam := d.ClassCounts()
+	if nonSpam == 0 || spam == 0 {
+		return nil, fmt.Errorf("Need held-out rows of both classes to calibrate")
+	}
+	labels := make([]float64, len(probs))
+	for i := range probs {
+		labels[i] = float64(d.Label(i))
+	}
+
+	c := &Calibration{Method: method, Rows: len(probs)}
+	switch method {
+	case CalibrationPlatt:
+		c.A, c.B = fitPlatt(probs, labels, float64(nonSpam), float64(spam))
+	case CalibrationIsotonic:
+		c.X, c.Y = fitIsotonic(probs, labels)
+	default:
+		return nil, fmt.Errorf("Unknown calibration %q, expected none, platt or isotonic", method)
+	}
+	return c, nil
+}
+
+// Apply returns the calibrated probability of a score
+func (c *Calibration) Apply(score float64) float64 {
+	switch c.Method {
+	case CalibrationPlatt:
+		return 1 / (1 + math.Exp(c.A*score+c.B))
+	case CalibrationIsotonic:
+		n := len(c.X)
+		if n == 0 {
+			return score
+		}
+		i := sort.SearchFloat64s(c.X, score)
+		switch {
+		case i == 0:
+			return c.Y[0]
+		case i == n:
+			return c.Y[n-1]
+		}
+		frac := (score - c.X[i-1]) / (c.X[i] - c.X[i-1])
+		return c.Y[i-1] + frac*(c.Y[i]-c.Y[i-1])
+	}
+	return score
+}
+
+// fitPlatt fits the sigmoid by Newton's method, with Platt's smoothed
+// targets so a perfectly separated split doesn't give infinite weights
+func fitPlatt(scores, labels []float64, nonSpam, spam float64) (a, b float64) {
+	hi, lo := (spam+1)/(spam+2), 1/(nonSpam+2)
+	targets := make([]float64, len(labels))
+	for i, label := range labels {
+		targets[i] = lo
+		if label == 1 {
+			targets[i] = hi
+		}
+	}
+
+	a, b = 0, math.Log((nonSpam+1)/(spam+1))
+	for iter := 0; iter < 100; iter++ {
+		// gradient and Hessian of the log-loss in a and b
+		ga, gb, haa, hab, hbb := 0.0, 0.0, 1e-12, 0.0, 1e-12
+		for i, s := range scores {
+			p := 1 / (1 + math.Exp(a*s+b))
+			d := targets[i] - p
+			w := p * (1 - p)
+			ga, gb = ga+s*d, gb+d
+			haa, hab, hbb = haa+s*s*w, hab+s*w, hbb+w
+		}
+		det := haa*hbb - hab*hab
+		if det <= 0 {
+			break
+		}
+		da := (hbb*ga - hab*gb) / det
+		db := (haa*gb - hab*ga) / det
+		a, b = a-da, b-db
+		if math.Abs(da) < 1e-10 && math.Abs(db) < 1e-10 {
+			break
+		}
+	}
+	return a, b
+}
+
+// fitIsotonic fits a non-decreasing function by pooling adjacent
+// violators, returning each pooled block's mean score and probability
+func fitIsotonic(scores, labels []float64) (xs, ys []float64) {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return scores[order[i]] < scores[order[j]]
+	})
+
+	type block struct{ x, y, n float64 }
+	blocks := []block{}
+	for _, i := range order {
+		b := block{scores[i], labels[i], 1}
+		// rows with the same score share a block
+		if n := len(blocks); n > 0 && blocks[n-1].x/blocks[n-1].n == b.x {
+			blocks[n-1].y += b.y
+			blocks[n-1].n++
+			blocks[n-1].x += b.x
+		} else {
+			blocks = append(blocks, b)
+		}
+		for n := len(blocks); n > 1 && blocks[n-2].y/blocks[n-2].n >= blocks[n-1].y/blocks[n-1].n; n-- {
+			last := blocks[n-1]
+			blocks = blocks[:n-1]
+			blocks[n-2].x += last.x
+			blocks[n-2].y += last.y
+			blocks[n-2].n += last.n
+		}
+	}
+
+	for _, b := range blocks {
+		xs = append(xs, b.x/b.n)
+		ys = append(ys, b.y/b.n)
+	}
+	return xs, ys
+}
+
+// Calibrated is a model whose scores are calibrated
+type Calibrated struct {
+	Model
+	Calibration *Calibration
+}
+
+// Proba returns the calibrated spam probability of each row of a dataset
+func (c Calibrated) Proba(d *Dataset) ([]float64, error) {
+	probs, err := c.Model.Proba(d)
+	if err != nil {
+		return nil, err
+	}
+	for i, prob := range probs {
+		probs[i] = c.Calibration.Apply(prob)
+	}
+	return probs, nil
+}
@@ -0,0 +1,51 @@
+package classify
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// overconfident returns scores that are more extreme than the true spam
+// probability p, and labels drawn from p
+func overconfident(n int, rng *rand.Rand) (*Dataset, []float64) {
+	d := &Dataset{Cols: []string{"is_spam"}}
+	scores := make([]float64, n)
+	for i := range scores {
+		p := rng.Float64()
+		label := 0.0
+		if rng.Float64() < p {
+			label = 1
+		}
+		d.Append([]float64{label}, RowMeta{})
+		scores[i] = math.Min(1, math.Max(0, 0.5+1.8*(p-0.5)))
+	}
+	return d, scores
+}
+
+func TestCalibrate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	d, scores := overconfident(5000, rng)
+
+	for _, method := range []string{CalibrationPlatt, CalibrationIsotonic} {
+		c, err := Calibrate(method, d, scores)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calibrated := make([]float64, len(scores))
+		for i, s := range scores {
+			calibrated[i] = c.Apply(s)
+		}
+		if before, after := LogLoss(d, scores), LogLoss(d, calibrated); after >= before {
+			t.Errorf("%s: log loss %.4f after calibrating, want below %.4f", method, after, before)
+		}
+		for _, s := range []float64{0.1, 0.5, 0.9} {
+			if got := c.Apply(s); got < 0 || got > 1 {
+				t.Errorf("%s: Apply(%v) = %v", method, s, got)
+			}
+		}
+		if c.Apply(0.2) > c.Apply(0.8) {
+			t.Errorf("%s: calibration isn't increasing", method)
+		}
+	}
+}
@@ -3,10 +3,10 @@ package classify
 import (
 	"encoding/gob"
 	"os"
-	"strconv"
 
 	"github.com/meiji163/gh-spam/spam"
 	"github.com/sjwhitworth/golearn/base"
+	"github.com/sjwhitworth/golearn/ensemble"
 )
 
 var InstanceCols = []string{
@@ -14,54 +14,181 @@ var InstanceCols = []string{
 	"contributions",
 	"repos",
 	"age",
+	"age_minutes",
+	"post_hour",
+	"post_weekday",
+	"prior_gap",
+	"edits",
 	"followers",
 	"following",
+	"has_name",
+	"has_bio",
+	"has_company",
+	"has_location",
+	"has_website",
+	"site_admin",
+	"is_bot",
+	"org_member",
+	"public_repos",
+	"gists",
+	"starred",
+	"active_days",
+	"longest_streak",
 	"body_len",
 	"title_len",
-	"sim_score",
+	"template_sim",
+	"headings_removed",
+	"empty_required",
+	"placeholders",
+	"dup_cluster",
+	"prior_issues",
+	"prior_prs",
+	"quick_closed",
+	"merged_prs",
+	"cross_repo",
 	"is_spam",
 }
 
-// convert issue features to a golearn Instances object
+// PullCols are the columns of pull request datasets
+var PullCols = withCols(
+	"changed_files",
+	"additions",
+	"deletions",
+	"docs_only",
+	"author_commits",
+)
+
+// DiscussionCols are the columns of discussion datasets
+var DiscussionCols = withCols(
+	"category",
+	"answered",
+)
+
+// CommentCols are the columns of comment datasets
+var CommentCols = withCols(
+	"comment_links",
+	"parent_sim",
+	"parent_age",
+)
+
+// withCols inserts extra columns before the class column of InstanceCols
+func withCols(cols ...string) []string {
+	n := len(InstanceCols) - 1
+	all := append([]string{}, InstanceCols[:n]...)
+	all = append(all, cols...)
+	return append(all, InstanceCols[n])
+}
+
+// Columns are the dataset columns for a kind of item
+func Columns(kind string) []string {
+	switch kind {
+	case spam.KindPR:
+		return PullCols
+	case spam.KindDiscussion:
+		return DiscussionCols
+	case spam.KindComment:
+		return CommentCols
+	default:
+		return InstanceCols
+	}
+}
+
+// floatCols are the columns that aren't ints, with their precision
+var floatCols = map[string]int{
+	"template_sim": 3,
+	"parent_sim":   3,
+}
+
+var colValues = map[string]func(spam.Features) float64{
+	"association":      func(f spam.Features) float64 { return float64(f.Association) },
+	"contributions":    func(f spam.Features) float64 { return float64(f.Contributions) },
+	"repos":            func(f spam.Features) float64 { return float64(f.AuthorRepos) },
+	"age":              func(f spam.Features) float64 { return float64(f.AccountAge) },
+	"age_minutes":      func(f spam.Features) float64 { return float64(f.AccountAgeMinutes) },
+	"post_hour":        func(f spam.Features) float64 { return float64(f.PostHour) },
+	"post_weekday":     func(f spam.Features) float64 { return float64(f.PostWeekday) },
+	"prior_gap":        func(f spam.Features) float64 { return float64(f.PriorGap) },
+	"edits":            func(f spam.Features) float64 { return float64(f.Edits) },
+	"followers":        func(f spam.Features) float64 { return float64(f.Followers) },
+	"following":        func(f spam.Features) float64 { return float64(f.Following) },
+	"has_name":         func(f spam.Features) float64 { return float64(f.HasName) },
+	"has_bio":          func(f spam.Features) float64 { return float64(f.HasBio) },
+	"has_company":      func(f spam.Features) float64 { return float64(f.HasCompany) },
+	"has_location":     func(f spam.Features) float64 { return float64(f.HasLocation) },
+	"has_website":      func(f spam.Features) float64 { return float64(f.HasWebsite) },
+	"site_admin":       func(f spam.Features) float64 { return float64(f.SiteAdmin) },
+	"is_bot":           func(f spam.Features) float64 { return float64(f.IsBot) },
+	"org_member":       func(f spam.Features) float64 { return float64(f.OrgMember) },
+	"public_repos":     func(f spam.Features) float64 { return float64(f.PublicRepos) },
+	"gists":            func(f spam.Features) float64 { return float64(f.PublicGists) },
+	"starred":          func(f spam.Features) float64 { return float64(f.StarredRepos) },
+	"active_days":      func(f spam.Features) float64 { return float64(f.ActiveDays) },
+	"longest_streak":   func(f spam.Features) float64 { return float64(f.LongestStreak) },
+	"body_len":         func(f spam.Features) float64 { return float64(f.BodyLen) },
+	"title_len":        func(f spam.Features) float64 { return float64(f.TitleLen) },
+	"template_sim":     func(f spam.Features) float64 { return f.TemplateSim },
+	"headings_removed": func(f spam.Features) float64 { return float64(f.HeadingsRemoved) },
+	"empty_required":   func(f spam.Features) float64 { return float64(f.EmptyRequired) },
+	"placeholders":     func(f spam.Features) float64 { return float64(f.Placeholders) },
+	"dup_cluster":      func(f spam.Features) float64 { return float64(f.DupClusterSize) },
+	"prior_issues":     func(f spam.Features) float64 { return float64(f.PriorIssues) },
+	"prior_prs":        func(f spam.Features) float64 { return float64(f.PriorPRs) },
+	"quick_closed":     func(f spam.Features) float64 { return float64(f.QuickClosed) },
+	"merged_prs":       func(f spam.Features) float64 { return float64(f.MergedPRs) },
+	"cross_repo":       func(f spam.Features) float64 { return float64(f.CrossRepoIssues) },
+	"changed_files":    func(f spam.Features) float64 { return float64(f.ChangedFiles) },
+	"additions":        func(f spam.Features) float64 { return float64(f.Additions) },
+	"deletions":        func(f spam.Features) float64 { return float64(f.Deletions) },
+	"docs_only":        func(f spam.Features) float64 { return float64(f.DocsOnly) },
+	"author_commits":   func(f spam.Features) float64 { return float64(f.AuthorCommits) },
+	"category":         func(f spam.Features) float64 { return float64(f.Category) },
+	"answered":         func(f spam.Features) float64 { return float64(f.Answered) },
+	"comment_links":    func(f spam.Features) float64 { return float64(f.CommentLinks) },
+	"parent_sim":       func(f spam.Features) float64 { return f.ParentSim },
+	"parent_age":       func(f spam.Features) float64 { return float64(f.ParentAge) },
+	"is_spam":          func(f spam.Features) float64 { return float64(f.IsSpam) },
+}
+
+// convert features to a golearn Instances object, with the columns
+// for the kind of the first row
 func FeaturesToInstances(feats []spam.Features) *base.DenseInstances {
-	attrs := make([]base.Attribute, len(InstanceCols))
-	for i, col := range InstanceCols {
-		attrs[i] = base.NewFloatAttribute(col)
-		attrs[i].(*base.FloatAttribute).Precision = 0 // all ints
+	kind := ""
+	if len(feats) > 0 {
+		kind = feats[0].Kind
 	}
+	return ToInstances(Columns(kind), feats)
+}
 
-	instances := base.NewDenseInstances()
-	specs := make([]base.AttributeSpec, len(attrs))
-	for i, attr := range attrs {
-		specs[i] = instances.AddAttribute(attr)
+// ToInstances converts features to a golearn Instances object with the given
+// columns. The last column is the class.
+func ToInstances(cols []string, feats []spam.Features) *base.DenseInstances {
+	return NewDataset(cols, feats).Instances()
+}
+
+// SpamProba returns the fraction of trees in the forest voting spam for each row
+func SpamProba(forest *ensemble.RandomForest, instances base.FixedDataGrid) ([]float64, error) {
+	_, rows := instances.Size()
+	probs := make([]float64, rows)
+	if forest.Model == nil || len(forest.Model.Models) == 0 {
+		return probs, nil
 	}
 
-	instances.AddClassAttribute(attrs[len(attrs)-1])
-	instances.Extend(len(feats))
-
-	for row := 0; row < len(feats); row++ {
-		feat := feats[row]
-		vals := []int{
-			feat.Association,
-			feat.Contributions,
-			feat.AuthorRepos,
-			feat.AccountAge,
-			feat.Followers,
-			feat.Following,
-			feat.BodyLen,
-			feat.TitleLen,
-			feat.TemplateScore,
-			feat.IsSpam}
-
-		for i := 0; i < len(specs); i++ {
-			instances.Set(
-				specs[i],
-				row,
-				specs[i].GetAttribute().GetSysValFromString(strconv.Itoa(vals[i])),
-			)
+	for _, tree := range forest.Model.Models {
+		pred, err := tree.Predict(instances)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < rows; i++ {
+			if base.GetClass(pred, i) != "0" {
+				probs[i]++
+			}
 		}
 	}
-	return instances
+
+	for i := range probs {
+		probs[i] /= float64(len(forest.Model.Models))
+	}
+	return probs, nil
 }
 
 func WriteGob(filePath string, object interface{}) error {
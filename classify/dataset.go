@@ -0,0 +1,475 @@
+package classify
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meiji163/gh-spam/spam"
+	"github.com/sjwhitworth/golearn/base"
+)
+
+// Dataset is a table of numeric features. The last column is the class.
+// Meta describes each row and isn't used for training.
+type Dataset struct {
+	Cols []string
+	Rows [][]float64
+	Meta []RowMeta
+}
+
+// RowMeta describes where a row of a dataset came from
+type RowMeta struct {
+	// Repo is the row's repository in OWNER/REPO format
+	Repo string
+	// Number of the issue, pull request or discussion, or the comment ID.
+	// 0 for synthetic rows and rows from old datasets.
+	Number int
+	// Author is the login of the item's author
+	Author string
+	// Confidence is ConfidenceHigh if a maintainer confirmed the label
+	Confidence string
+	// Source is how the row was labeled: SourceHeuristic, SourceManual or SourceFeedback
+	Source string
+	// FetchedAt is when the row's features were downloaded
+	FetchedAt time.Time
+}
+
+// ConfidenceHigh marks labels confirmed by a maintainer
+const ConfidenceHigh = "high"
+
+// Label sources
+const (
+	// SourceHeuristic labels come from download's spam searches and rules
+	SourceHeuristic = "heuristic"
+	// SourceManual labels were set with the dataset label command
+	SourceManual = "manual"
+	// SourceFeedback labels come from maintainers' labeling, via sync-feedback
+	SourceFeedback = "feedback"
+)
+
+// highConfidenceWeight is how many times high confidence rows are repeated in training
+const highConfidenceWeight = 3
+
+// metaCols are written before the feature columns in CSV files
+var metaCols = []string{"repo", "number", "author", "confidence", "label_source", "fetched_at"}
+
+// ID identifies the row's item, e.g. cli/cli#4894
+func (m RowMeta) ID() string {
+	if m.Number == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s#%d", m.Repo, m.Number)
+}
+
+// NewDataset makes a dataset of features with the given columns
+func NewDataset(cols []string, feats []spam.Features) *Dataset {
+	d := &Dataset{Cols: cols}
+	for _, feat := range feats {
+		row := make([]float64, len(cols))
+		for i, col := range cols {
+			row[i] = colValues[col](feat)
+		}
+		d.Append(row, RowMeta{Number: feat.Number, Author: feat.Author})
+	}
+	return d
+}
+
+// SetProvenance records the repo, label source and fetch time of rows
+// that don't have them yet
+func (d *Dataset) SetProvenance(repo, source string, fetchedAt time.Time) {
+	for i := range d.Meta {
+		meta := &d.Meta[i]
+		if meta.Repo == "" {
+			meta.Repo = repo
+		}
+		if meta.Source == "" {
+			meta.Source = source
+		}
+		if meta.FetchedAt.IsZero() {
+			meta.FetchedAt = fetchedAt
+		}
+	}
+}
+
+// Append adds a row
+func (d *Dataset) Append(row []float64, meta RowMeta) {
+	d.Rows = append(d.Rows, row)
+	d.Meta = append(d.Meta, meta)
+}
+
+// Find returns the index of the row for an item in a repo, or -1. An empty
+// repo, or a row without one, matches any repo; it is an error if more than
+// one row matches.
+func (d *Dataset) Find(repo string, number int) (int, error) {
+	if number == 0 {
+		return -1, nil
+	}
+	found := -1
+	for i, meta := range d.Meta {
+		if meta.Number != number || (repo != "" && meta.Repo != "" && !strings.EqualFold(meta.Repo, repo)) {
+			continue
+		}
+		if found >= 0 && d.Meta[found].ID() != meta.ID() {
+			return -1, fmt.Errorf("#%d matches %s and %s, give the repo as OWNER/REPO#%d", number, d.Meta[found].ID(), meta.ID(), number)
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	return found, nil
+}
+
+// SetLabel sets the class of a row, 1 for spam and 0 for not spam
+func (d *Dataset) SetLabel(row, label int) {
+	d.Rows[row][len(d.Cols)-1] = float64(label)
+}
+
+// Confirmed returns the rows whose labels a maintainer confirmed
+func (d *Dataset) Confirmed() *Dataset {
+	rows := []int{}
+	for i, meta := range d.Meta {
+		if meta.Confidence == ConfidenceHigh {
+			rows = append(rows, i)
+		}
+	}
+	return d.subset(rows)
+}
+
+// WeightConfidence repeats high confidence rows so they count for more in training
+func WeightConfidence(d *Dataset) *Dataset {
+	rows := []int{}
+	for i, meta := range d.Meta {
+		rows = append(rows, i)
+		if meta.Confidence == ConfidenceHigh {
+			for w := 1; w < highConfidenceWeight; w++ {
+				rows = append(rows, i)
+			}
+		}
+	}
+	if len(rows) == len(d.Rows) {
+		return d
+	}
+	return d.subset(rows)
+}
+
+// ReadDataset reads a dataset from a CSV file with a header row
+func ReadDataset(path string) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+
+	// meta columns come first. Older datasets have fewer or none of them.
+	header := records[0]
+	isMeta := map[string]bool{}
+	for _, col := range metaCols {
+		isMeta[col] = true
+	}
+	nMeta := 0
+	for nMeta < len(header) && isMeta[header[nMeta]] {
+		nMeta++
+	}
+
+	d := &Dataset{Cols: header[nMeta:]}
+	for i, record := range records[1:] {
+		meta, err := parseMeta(header[:nMeta], record[:nMeta])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %s", path, i+2, err)
+		}
+
+		row := make([]float64, len(record)-nMeta)
+		for j, val := range record[nMeta:] {
+			row[j], err = strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: invalid %s %q", path, i+2, d.Cols[j], val)
+			}
+		}
+		d.Append(row, meta)
+	}
+	return d, nil
+}
+
+// parseMeta reads the meta columns of a CSV record
+func parseMeta(cols, values []string) (RowMeta, error) {
+	var meta RowMeta
+	var err error
+	for i, col := range cols {
+		val := values[i]
+		switch col {
+		case "repo":
+			meta.Repo = val
+		case "number":
+			if meta.Number, err = strconv.Atoi(val); err != nil {
+				return meta, fmt.Errorf("invalid number %q", val)
+			}
+		case "author":
+			meta.Author = val
+		case "confidence":
+			meta.Confidence = val
+		case "label_source":
+			meta.Source = val
+		case "fetched_at":
+			if val == "" {
+				continue
+			}
+			if meta.FetchedAt, err = time.Parse(time.RFC3339, val); err != nil {
+				return meta, fmt.Errorf("invalid fetched_at %q", val)
+			}
+		}
+	}
+	return meta, nil
+}
+
+// metaValues formats the meta columns of a row
+func metaValues(meta RowMeta) []string {
+	fetchedAt := ""
+	if !meta.FetchedAt.IsZero() {
+		fetchedAt = meta.FetchedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{meta.Repo, strconv.Itoa(meta.Number), meta.Author, meta.Confidence, meta.Source, fetchedAt}
+}
+
+// WriteDataset writes a dataset to a CSV file with a header row
+func WriteDataset(path string, d *Dataset) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(append(append([]string{}, metaCols...), d.Cols...)); err != nil {
+		return err
+	}
+	for i, row := range d.Rows {
+		record := metaValues(d.Meta[i])
+		for j, val := range row {
+			record = append(record, strconv.FormatFloat(val, 'f', floatCols[d.Cols[j]], 64))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Instances converts the dataset to a golearn Instances object
+func (d *Dataset) Instances() *base.DenseInstances {
+	attrs := make([]base.Attribute, len(d.Cols))
+	for i, col := range d.Cols {
+		attrs[i] = base.NewFloatAttribute(col)
+		attrs[i].(*base.FloatAttribute).Precision = floatCols[col]
+	}
+
+	instances := base.NewDenseInstances()
+	specs := make([]base.AttributeSpec, len(attrs))
+	for i, attr := range attrs {
+		specs[i] = instances.AddAttribute(attr)
+	}
+
+	instances.AddClassAttribute(attrs[len(attrs)-1])
+	instances.Extend(len(d.Rows))
+
+	for r, row := range d.Rows {
+		for i, val := range row {
+			instances.Set(specs[i], r, base.PackFloatToBytes(val))
+		}
+	}
+	return instances
+}
+
+// Label is the class of a row, 1 for spam and 0 for not spam
+func (d *Dataset) Label(row int) int {
+	return int(d.Rows[row][len(d.Cols)-1])
+}
+
+// ClassCounts returns the number of non-spam and spam rows
+func (d *Dataset) ClassCounts() (nonSpam, spam int) {
+	for i := range d.Rows {
+		if d.Label(i) == 1 {
+			spam++
+		} else {
+			nonSpam++
+		}
+	}
+	return nonSpam, spam
+}
+
+// Balance describes the class balance
+func (d *Dataset) Balance() string {
+	nonSpam, spam := d.ClassCounts()
+	pct := 0.0
+	if len(d.Rows) > 0 {
+		pct = 100 * float64(spam) / float64(len(d.Rows))
+	}
+	return fmt.Sprintf("%d rows: %d not spam, %d spam (%.1f%% spam)", len(d.Rows), nonSpam, spam, pct)
+}
+
+// ColStats summarizes a column of a dataset
+type ColStats struct {
+	Col         string
+	Min, Max    float64
+	Mean, Std   float64
+	SpamMean    float64
+	NotSpamMean float64
+}
+
+// Stats summarizes each column of the dataset
+func (d *Dataset) Stats() []ColStats {
+	stats := make([]ColStats, len(d.Cols))
+	nonSpam, spam := d.ClassCounts()
+	for c, col := range d.Cols {
+		s := ColStats{Col: col, Min: math.Inf(1), Max: math.Inf(-1)}
+		sum, sumSq := 0.0, 0.0
+		for i, row := range d.Rows {
+			v := row[c]
+			s.Min, s.Max = math.Min(s.Min, v), math.Max(s.Max, v)
+			sum += v
+			sumSq += v * v
+			if d.Label(i) == 1 {
+				s.SpamMean += v
+			} else {
+				s.NotSpamMean += v
+			}
+		}
+		if n := float64(len(d.Rows)); n > 0 {
+			s.Mean = sum / n
+			s.Std = math.Sqrt(math.Max(0, sumSq/n-s.Mean*s.Mean))
+		} else {
+			s.Min, s.Max = 0, 0
+		}
+		if spam > 0 {
+			s.SpamMean /= float64(spam)
+		}
+		if nonSpam > 0 {
+			s.NotSpamMean /= float64(nonSpam)
+		}
+		stats[c] = s
+	}
+	return stats
+}
+
+// Merge combines datasets with the same columns, in the column order of the first
+func Merge(datasets ...*Dataset) (*Dataset, error) {
+	if len(datasets) == 0 {
+		return nil, fmt.Errorf("no datasets to merge")
+	}
+	out := &Dataset{Cols: datasets[0].Cols}
+	for n, d := range datasets {
+		if len(d.Cols) != len(out.Cols) {
+			return nil, fmt.Errorf("dataset %d has %d columns, expected %d", n+1, len(d.Cols), len(out.Cols))
+		}
+		index := map[string]int{}
+		for c, col := range d.Cols {
+			index[col] = c
+		}
+		order := make([]int, len(out.Cols))
+		for c, col := range out.Cols {
+			i, ok := index[col]
+			if !ok {
+				return nil, fmt.Errorf("dataset %d is missing column %s", n+1, col)
+			}
+			order[c] = i
+		}
+
+		for i, row := range d.Rows {
+			merged := make([]float64, len(order))
+			for c, j := range order {
+				merged[c] = row[j]
+			}
+			out.Append(merged, d.Meta[i])
+		}
+	}
+	return out, nil
+}
+
+// Dedupe keeps one row for each item, preferring high confidence rows and
+// then later rows. Rows without a number are kept. It returns the number of
+// rows removed.
+func (d *Dataset) Dedupe() (*Dataset, int) {
+	keep := map[string]int{}
+	for i, meta := range d.Meta {
+		id := meta.ID()
+		if id == "" {
+			continue
+		}
+		j, ok := keep[id]
+		if !ok || meta.Confidence == ConfidenceHigh || d.Meta[j].Confidence != ConfidenceHigh {
+			keep[id] = i
+		}
+	}
+
+	rows := []int{}
+	for i, meta := range d.Meta {
+		if id := meta.ID(); id == "" || keep[id] == i {
+			rows = append(rows, i)
+		}
+	}
+	return d.subset(rows), len(d.Rows) - len(rows)
+}
+
+// Split makes a stratified train/test split with about testFrac of each
+// class in the test set
+func Split(d *Dataset, testFrac float64, rng *rand.Rand) (train, test *Dataset) {
+	var trainRows, testRows []int
+	for _, class := range []int{0, 1} {
+		rows := []int{}
+		for i := range d.Rows {
+			if d.Label(i) == class {
+				rows = append(rows, i)
+			}
+		}
+		rng.Shuffle(len(rows), func(i, j int) {
+			rows[i], rows[j] = rows[j], rows[i]
+		})
+		n := int(math.Round(testFrac * float64(len(rows))))
+		testRows = append(testRows, rows[:n]...)
+		trainRows = append(trainRows, rows[n:]...)
+	}
+	sort.Ints(trainRows)
+	sort.Ints(testRows)
+	return d.subset(trainRows), d.subset(testRows)
+}
+
+// WriteJSONL writes a dataset as JSON lines, one object per row with the
+// meta columns first
+func WriteJSONL(w io.Writer, d *Dataset) error {
+	bw := bufio.NewWriter(w)
+	for i, row := range d.Rows {
+		bw.WriteString("{")
+		for c, val := range metaValues(d.Meta[i]) {
+			if c > 0 {
+				bw.WriteString(",")
+			}
+			if metaCols[c] != "number" {
+				b, _ := json.Marshal(val)
+				val = string(b)
+			}
+			fmt.Fprintf(bw, "%q:%s", metaCols[c], val)
+		}
+		for c, col := range d.Cols {
+			fmt.Fprintf(bw, `,%q:%s`, col, strconv.FormatFloat(row[c], 'f', -1, 64))
+		}
+		bw.WriteString("}\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,165 @@
+package classify
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	a := &Dataset{Cols: []string{"age", "followers", "is_spam"}}
+	a.Append([]float64{10, 1, 1}, RowMeta{Number: 1})
+	b := &Dataset{Cols: []string{"followers", "age", "is_spam"}}
+	b.Append([]float64{5, 200, 0}, RowMeta{Number: 2})
+
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := merged.Rows[1]; got[0] != 200 || got[1] != 5 || got[2] != 0 {
+		t.Errorf("got row %v, want columns reordered to [200 5 0]", got)
+	}
+	if merged.Meta[1].Number != 2 {
+		t.Errorf("got number %d, want 2", merged.Meta[1].Number)
+	}
+
+	c := &Dataset{Cols: []string{"age", "stars", "is_spam"}}
+	if _, err := Merge(a, c); err == nil {
+		t.Error("expected an error merging different columns")
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	d := &Dataset{Cols: []string{"age", "is_spam"}}
+	d.Append([]float64{1, 1}, RowMeta{Number: 1, Confidence: ConfidenceHigh})
+	d.Append([]float64{2, 0}, RowMeta{Number: 1})
+	d.Append([]float64{3, 0}, RowMeta{Number: 2})
+	d.Append([]float64{4, 1}, RowMeta{Number: 2})
+	d.Append([]float64{5, 0}, RowMeta{})
+	d.Append([]float64{6, 0}, RowMeta{})
+
+	deduped, removed := d.Dedupe()
+	if removed != 2 {
+		t.Errorf("removed %d rows, want 2", removed)
+	}
+	got := []float64{}
+	for _, row := range deduped.Rows {
+		got = append(got, row[0])
+	}
+	want := []float64{1, 4, 5, 6}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("kept rows %v, want %v", got, want)
+		}
+	}
+
+	// the same number in different repos is a different item
+	merged := &Dataset{Cols: []string{"age", "is_spam"}}
+	merged.Append([]float64{1, 0}, RowMeta{Repo: "cli/cli", Number: 1})
+	merged.Append([]float64{2, 1}, RowMeta{Repo: "cli/go-gh", Number: 1})
+	merged.Append([]float64{3, 1}, RowMeta{Repo: "cli/cli", Number: 1})
+	deduped, removed = merged.Dedupe()
+	if removed != 1 || len(deduped.Rows) != 2 || deduped.Rows[0][0] != 2 || deduped.Rows[1][0] != 3 {
+		t.Errorf("got rows %v, want one for each repo's #1", deduped.Rows)
+	}
+}
+
+func TestFind(t *testing.T) {
+	d := &Dataset{Cols: []string{"age", "is_spam"}}
+	d.Append([]float64{1, 0}, RowMeta{Repo: "cli/cli", Number: 1})
+	d.Append([]float64{2, 1}, RowMeta{Repo: "cli/go-gh", Number: 1})
+	d.Append([]float64{3, 1}, RowMeta{Repo: "cli/cli", Number: 2})
+
+	tests := []struct {
+		repo    string
+		number  int
+		want    int
+		wantErr bool
+	}{
+		{"cli/go-gh", 1, 1, false},
+		{"CLI/CLI", 1, 0, false},
+		{"", 2, 2, false},
+		{"", 1, -1, true},
+		{"cli/go-gh", 2, -1, false},
+	}
+	for _, tt := range tests {
+		got, err := d.Find(tt.repo, tt.number)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Find(%q, %d) = %d, %v, want %d", tt.repo, tt.number, got, err, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	d := syntheticDataset(200, rng)
+	train, test := Split(d, 0.25, rng)
+	if len(train.Rows)+len(test.Rows) != len(d.Rows) {
+		t.Fatalf("split %d rows into %d and %d", len(d.Rows), len(train.Rows), len(test.Rows))
+	}
+	_, spam := d.ClassCounts()
+	_, testSpam := test.ClassCounts()
+	if want := int(0.25*float64(spam) + 0.5); testSpam != want {
+		t.Errorf("got %d spam rows in test set, want %d", testSpam, want)
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	d := &Dataset{Cols: []string{"age", "is_spam"}}
+	d.Append([]float64{1.5, 1}, RowMeta{Repo: "cli/cli", Number: 7, Author: "monalisa", Confidence: ConfidenceHigh, Source: SourceFeedback})
+
+	var buf bytes.Buffer
+	if err := WriteJSONL(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"repo":"cli/cli","number":7,"author":"monalisa","confidence":"high","label_source":"feedback","fetched_at":"","age":1.5,"is_spam":1}`
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDatasetProvenance(t *testing.T) {
+	dir := t.TempDir()
+	fetched := time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC)
+	d := &Dataset{Cols: []string{"age", "is_spam"}}
+	d.Append([]float64{2, 1}, RowMeta{Number: 4894, Author: "spammer"})
+	d.SetProvenance("cli/cli", SourceHeuristic, fetched)
+
+	path := filepath.Join(dir, "new.csv")
+	if err := WriteDataset(path, d); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadDataset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RowMeta{Repo: "cli/cli", Number: 4894, Author: "spammer", Source: SourceHeuristic, FetchedAt: fetched}
+	if got.Meta[0] != want {
+		t.Errorf("got meta %+v, want %+v", got.Meta[0], want)
+	}
+	if len(got.Cols) != 2 || got.Rows[0][0] != 2 {
+		t.Errorf("got cols %v and row %v, want provenance kept out of the features", got.Cols, got.Rows[0])
+	}
+
+	// datasets from before provenance have only some meta columns, or none
+	for name, csv := range map[string]string{
+		"number.csv": "number,confidence,age,is_spam\n4894,high,2,1\n",
+		"plain.csv":  "age,is_spam\n2,1\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(csv), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadDataset(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Cols) != 2 || got.Rows[0][0] != 2 {
+			t.Errorf("%s: got cols %v and row %v", name, got.Cols, got.Rows[0])
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package classify
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Prediction is a logged classification, with the human label once known
+type Prediction struct {
+	Time     time.Time          `json:"time"`
+	Kind     string             `json:"kind"`
+	Number   int                `json:"number"`
+	URL      string             `json:"url,omitempty"`
+	Score    float64            `json:"score"`
+	Verdict  string             `json:"verdict"`
+	Applied  bool               `json:"applied,omitempty"`
+	Features map[string]float64 `json:"features"`
+
+	// Label is "spam" or "not spam" once a human has labeled the item
+	Label     string     `json:"label,omitempty"`
+	LabeledAt *time.Time `json:"labeled_at,omitempty"`
+}
+
+// AppendPredictions adds predictions to a JSON lines log
+func AppendPredictions(path string, preds []Prediction) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, pred := range preds {
+		if err := enc.Encode(pred); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPredictions reads a prediction log. A missing log is empty.
+func ReadPredictions(path string) ([]Prediction, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	preds := []Prediction{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var pred Prediction
+		if err := json.Unmarshal(scanner.Bytes(), &pred); err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+	return preds, scanner.Err()
+}
+
+// WritePredictions replaces a prediction log
+func WritePredictions(path string, preds []Prediction) error {
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := AppendPredictions(tmp, preds); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// psiBins is the number of quantile bins used to compare distributions
+const psiBins = 10
+
+// PSI is the population stability index of a feature, comparing recent
+// values to the training values. Under 0.1 is stable, and over 0.25 is
+// a large shift.
+func PSI(train, recent []float64) float64 {
+	if len(train) == 0 || len(recent) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, train...)
+	sort.Float64s(sorted)
+
+	// bin edges at the training quantiles; discrete features get fewer bins
+	edges := []float64{}
+	for i := 1; i < psiBins; i++ {
+		edge := sorted[i*len(sorted)/psiBins]
+		if len(edges) == 0 || edge > edges[len(edges)-1] {
+			edges = append(edges, edge)
+		}
+	}
+
+	hist := func(values []float64) []float64 {
+		counts := make([]float64, len(edges)+1)
+		for _, v := range values {
+			counts[sort.SearchFloat64s(edges, v)]++
+		}
+		for i := range counts {
+			// smooth empty bins so the log is finite
+			counts[i] = (counts[i] + 0.5) / (float64(len(values)) + 0.5*float64(len(counts)))
+		}
+		return counts
+	}
+
+	expected, actual := hist(train), hist(recent)
+	psi := 0.0
+	for i := range expected {
+		psi += (actual[i] - expected[i]) * math.Log(actual[i]/expected[i])
+	}
+	return psi
+}
+
+// FeatureDrift is the PSI of a feature
+type FeatureDrift struct {
+	Col string
+	PSI float64
+}
+
+// Drift compares the features of predictions to a training dataset,
+// largest shift first
+func Drift(train *Dataset, preds []Prediction) []FeatureDrift {
+	drift := []FeatureDrift{}
+	for c, col := range train.Cols[:len(train.Cols)-1] {
+		trainVals := make([]float64, len(train.Rows))
+		for i, row := range train.Rows {
+			trainVals[i] = row[c]
+		}
+		recentVals := []float64{}
+		for _, pred := range preds {
+			if v, ok := pred.Features[col]; ok {
+				recentVals = append(recentVals, v)
+			}
+		}
+		if len(recentVals) == 0 {
+			continue
+		}
+		drift = append(drift, FeatureDrift{Col: col, PSI: PSI(trainVals, recentVals)})
+	}
+	sort.SliceStable(drift, func(i, j int) bool {
+		return drift[i].PSI > drift[j].PSI
+	})
+	return drift
+}
+
+// Precision is the fraction of labeled spam verdicts that humans agreed
+// with, and the number of labeled spam verdicts
+func Precision(preds []Prediction) (float64, int) {
+	agreed, labeled := 0, 0
+	for _, pred := range preds {
+		if pred.Verdict != "spam" || pred.Label == "" {
+			continue
+		}
+		labeled++
+		if pred.Label == "spam" {
+			agreed++
+		}
+	}
+	if labeled == 0 {
+		return 0, 0
+	}
+	return float64(agreed) / float64(labeled), labeled
+}
+
+// TrainPrecision is the precision of spam probabilities at or above
+// threshold on a dataset
+func TrainPrecision(d *Dataset, probs []float64, threshold float64) float64 {
+	tp, fp := 0.0, 0.0
+	for i, prob := range probs {
+		if prob < threshold {
+			continue
+		}
+		if d.Label(i) == 1 {
+			tp++
+		} else {
+			fp++
+		}
+	}
+	if tp+fp == 0 {
+		return 0
+	}
+	return tp / (tp + fp)
+}
@@ -0,0 +1,153 @@
+package classify
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/meiji163/gh-spam/classify/forest"
+	"github.com/sjwhitworth/golearn/base"
+	"github.com/sjwhitworth/golearn/ensemble"
+	"github.com/sjwhitworth/golearn/trees"
+)
+
+// ExportForest converts a trained forest to the forest package's format.
+// cols are the dataset columns; the class column is dropped.
+func ExportForest(rf *ensemble.RandomForest, cols []string) (*forest.Forest, error) {
+	if rf.Model == nil || len(rf.Model.Models) == 0 {
+		return nil, fmt.Errorf("forest is not trained")
+	}
+
+	features := cols[:len(cols)-1]
+	index := map[string]int{}
+	for i, col := range features {
+		index[col] = i
+	}
+
+	out := &forest.Forest{
+		Format:   forest.Format,
+		Version:  forest.Version,
+		Features: features,
+	}
+	for _, model := range rf.Model.Models {
+		id3, ok := model.(*trees.ID3DecisionTree)
+		if !ok || id3.Root == nil {
+			return nil, fmt.Errorf("unsupported tree %T", model)
+		}
+		tree := forest.Tree{}
+		if _, err := exportNode(&tree, id3.Root, index); err != nil {
+			return nil, err
+		}
+		out.Trees = append(out.Trees, tree)
+	}
+	return out, nil
+}
+
+// exportNode appends a node and its subtree in preorder, returning its index.
+// It follows golearn's ID3 prediction: numeric splits send values above the
+// threshold to child "1", and a split with one child always takes it.
+func exportNode(tree *forest.Tree, node *trees.DecisionTreeNode, index map[string]int) (int, error) {
+	i := len(tree.Nodes)
+	if node.Children == nil {
+		class := 1
+		if node.Class == "0" {
+			class = 0
+		}
+		tree.Nodes = append(tree.Nodes, forest.Node{Leaf: true, Class: class})
+		return i, nil
+	}
+
+	if len(node.Children) == 1 {
+		for _, child := range node.Children {
+			return exportNode(tree, child, index)
+		}
+	}
+
+	attr := node.SplitRule.SplitAttr
+	if _, ok := attr.(*base.FloatAttribute); !ok {
+		return 0, fmt.Errorf("unsupported split on %s", attr)
+	}
+	feature, ok := index[attr.GetName()]
+	if !ok {
+		return 0, fmt.Errorf("tree splits on unknown column %s", attr.GetName())
+	}
+	left, right := node.Children["0"], node.Children["1"]
+	if left == nil || right == nil || len(node.Children) != 2 {
+		return 0, fmt.Errorf("unsupported split on %s with %d children", attr.GetName(), len(node.Children))
+	}
+
+	tree.Nodes = append(tree.Nodes, forest.Node{Feature: feature, Threshold: node.SplitRule.SplitVal})
+	l, err := exportNode(tree, left, index)
+	if err != nil {
+		return 0, err
+	}
+	r, err := exportNode(tree, right, index)
+	if err != nil {
+		return 0, err
+	}
+	tree.Nodes[i].Left, tree.Nodes[i].Right = l, r
+	return i, nil
+}
+
+// ForestProba returns the spam probability of each row of a dataset with
+// an exported forest. The dataset must have the forest's features.
+func ForestProba(f *forest.Forest, d *Dataset) ([]float64, error) {
+	cols, err := featureIndex(f.Features, d.Cols)
+	if err != nil {
+		return nil, err
+	}
+
+	probs := make([]float64, len(d.Rows))
+	row := make([]float64, len(cols))
+	for r, values := range d.Rows {
+		for i, c := range cols {
+			row[i] = values[c]
+		}
+		probs[r] = f.Proba(row)
+	}
+	return probs, nil
+}
+
+// featureIndex returns the index in cols of each feature
+func featureIndex(features, cols []string) ([]int, error) {
+	index := map[string]int{}
+	for i, col := range cols {
+		index[col] = i
+	}
+	out := make([]int, len(features))
+	for i, name := range features {
+		c, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("dataset is missing feature %s", name)
+		}
+		out[i] = c
+	}
+	return out, nil
+}
+
+// Forest is an exported forest used as a model
+type Forest struct {
+	*forest.Forest
+}
+
+// Proba returns the spam probability of each row of a dataset
+func (f Forest) Proba(d *Dataset) ([]float64, error) {
+	return ForestProba(f.Forest, d)
+}
+
+// OpenModel loads a saved model to classify with, either an exported
+// forest (.json) or a trained model. A trained model's metadata is
+// returned too; an exported forest has none.
+func OpenModel(modelPath string) (Model, *ModelMeta, error) {
+	if filepath.Ext(modelPath) == ".json" {
+		f, err := forest.Load(modelPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return Forest{f}, nil, nil
+	}
+	model, meta, err := LoadModel(modelPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return model, &meta, nil
+}
@@ -0,0 +1,73 @@
+package classify
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/meiji163/gh-spam/classify/forest"
+)
+
+func syntheticDataset(rows int, rng *rand.Rand) *Dataset {
+	d := &Dataset{Cols: []string{"age", "followers", "template_sim", "body_len", "is_spam"}}
+	for i := 0; i < rows; i++ {
+		spam := rng.Intn(3) == 0
+		row := []float64{
+			float64(rng.Intn(1000)),
+			float64(rng.Intn(50)),
+			rng.Float64(),
+			float64(rng.Intn(2000)),
+			0,
+		}
+		if spam {
+			row[0] /= 10
+			row[2] /= 2
+			row[4] = 1
+		}
+		d.Append(row, RowMeta{Number: i + 1})
+	}
+	return d
+}
+
+func TestExportForest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	train, test := syntheticDataset(300, rng), syntheticDataset(100, rng)
+
+	for _, p := range []Params{
+		{Classifier: ClassifierForest, Trees: 15, Features: 2},
+		{Classifier: ClassifierBagging, Trees: 9, MaxDepth: 3},
+	} {
+		model, err := Fit(train, p, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rf := model.(RandomForest).RandomForest
+		exported, err := ExportForest(rf, train.Cols)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := exported.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := forest.Read(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := SpamProba(rf, test.Instances())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ForestProba(loaded, test)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: row %d: got probability %v, want %v", p, i, got[i], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package forest evaluates random forests exported by gh-spam, using only
+// the standard library, so other programs can embed spam scoring.
+//
+// A forest is stored as JSON:
+//
+//	{
+//	  "format": "gh-spam-forest",
+//	  "version": 2,
+//	  "features": ["association", "contributions", ...],
+//	  "trees": [
+//	    {"nodes": [
+//	      {"feature": 3, "threshold": 12.5, "left": 1, "right": 2},
+//	      {"leaf": true, "class": 0},
+//	      {"leaf": true, "class": 1, "proba": 0.9}
+//	    ]}
+//	  ]
+//	}
+//
+// Features are the input columns in order. Each tree is a list of nodes
+// with the root first. A split node sends a row to its left node if the
+// row's feature is at most the threshold, otherwise to its right node.
+// A leaf node predicts its class, 1 for spam and 0 for not spam, and may
+// give the spam probability of the training rows that reached it as
+// "proba". The spam probability of a row is the mean over the trees of
+// their leaf's proba, or of their leaf's class if it has none; for forests
+// exported from golearn this is the fraction of trees that predict spam.
+// Version 1 forests have no proba.
+package forest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Format and Version identify the JSON format
+const (
+	Format  = "gh-spam-forest"
+	Version = 2
+)
+
+// Forest is a random forest of binary decision trees
+type Forest struct {
+	Format   string   `json:"format"`
+	Version  int      `json:"version"`
+	Features []string `json:"features"`
+	Trees    []Tree   `json:"trees"`
+}
+
+// Tree is a decision tree. Nodes[0] is the root.
+type Tree struct {
+	Nodes []Node `json:"nodes"`
+}
+
+// Node is a split or a leaf of a tree
+type Node struct {
+	Leaf  bool     `json:"leaf,omitempty"`
+	Class int      `json:"class,omitempty"`
+	Proba *float64 `json:"proba,omitempty"`
+
+	Feature   int     `json:"feature,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Left      int     `json:"left,omitempty"`
+	Right     int     `json:"right,omitempty"`
+}
+
+// Read decodes and validates a forest
+func Read(r io.Reader) (*Forest, error) {
+	var f Forest
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Load reads a forest from a file
+func Load(path string) (*Forest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Write encodes a forest
+func (f *Forest) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(f)
+}
+
+// Save writes a forest to a file
+func (f *Forest) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Validate checks the format, and that every split refers to a feature and
+// to nodes after it, so evaluation always terminates
+func (f *Forest) Validate() error {
+	if f.Format != Format || f.Version < 1 || f.Version > Version {
+		return fmt.Errorf("unsupported forest format %q version %d", f.Format, f.Version)
+	}
+	if len(f.Trees) == 0 {
+		return fmt.Errorf("forest has no trees")
+	}
+	for t, tree := range f.Trees {
+		if len(tree.Nodes) == 0 {
+			return fmt.Errorf("tree %d has no nodes", t)
+		}
+		for n, node := range tree.Nodes {
+			if node.Leaf {
+				if node.Proba != nil && (*node.Proba < 0 || *node.Proba > 1) {
+					return fmt.Errorf("tree %d node %d: invalid proba %v", t, n, *node.Proba)
+				}
+				continue
+			}
+			if node.Feature < 0 || node.Feature >= len(f.Features) {
+				return fmt.Errorf("tree %d node %d: invalid feature %d", t, n, node.Feature)
+			}
+			if node.Left <= n || node.Left >= len(tree.Nodes) || node.Right <= n || node.Right >= len(tree.Nodes) {
+				return fmt.Errorf("tree %d node %d: invalid children", t, n)
+			}
+		}
+	}
+	return nil
+}
+
+// leaf returns the leaf a row of features reaches
+func (t Tree) leaf(row []float64) Node {
+	node := t.Nodes[0]
+	for !node.Leaf {
+		if row[node.Feature] <= node.Threshold {
+			node = t.Nodes[node.Left]
+		} else {
+			node = t.Nodes[node.Right]
+		}
+	}
+	return node
+}
+
+// Predict returns the class a tree predicts for a row of features
+func (t Tree) Predict(row []float64) int {
+	return t.leaf(row).Class
+}
+
+// Proba returns the spam probability a tree gives a row of features
+func (t Tree) Proba(row []float64) float64 {
+	node := t.leaf(row)
+	if node.Proba != nil {
+		return *node.Proba
+	}
+	return float64(node.Class)
+}
+
+// Score returns the spam probability of a row of features, in the order
+// of f.Features, or an error if the row is missing features
+func (f *Forest) Score(row []float64) (float64, error) {
+	if len(row) < len(f.Features) {
+		return 0, fmt.Errorf("forest: row has %d features, expected %d", len(row), len(f.Features))
+	}
+	sum := 0.0
+	for _, tree := range f.Trees {
+		sum += tree.Proba(row)
+	}
+	return sum / float64(len(f.Trees)), nil
+}
+
+// Proba returns the spam probability of a row of features, in the order
+// of f.Features. It panics if the row is missing features; use Score for
+// rows of unknown length.
+func (f *Forest) Proba(row []float64) float64 {
+	prob, err := f.Score(row)
+	if err != nil {
+		panic(err)
+	}
+	return prob
+}
+
+// ProbaMap returns the spam probability of features by name. Missing
+// features are 0.
+func (f *Forest) ProbaMap(features map[string]float64) float64 {
+	row := make([]float64, len(f.Features))
+	for i, name := range f.Features {
+		row[i] = features[name]
+	}
+	return f.Proba(row)
+}
+
+// Predict returns 1 if most trees predict spam, otherwise 0. Like Proba,
+// it panics if the row is missing features.
+func (f *Forest) Predict(row []float64) int {
+	if f.Proba(row) > 0.5 {
+		return 1
+	}
+	return 0
+}
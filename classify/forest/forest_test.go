@@ -0,0 +1,118 @@
+package forest
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stumps splits on followers, then on age
+const stumps = `{
+  "format": "gh-spam-forest",
+  "version": 2,
+  "features": ["age", "followers"],
+  "trees": [
+    {"nodes": [
+      {"feature": 1, "threshold": 5, "left": 1, "right": 2},
+      {"leaf": true, "class": 1, "proba": 0.8},
+      {"leaf": true, "class": 0, "proba": 0.1}
+    ]},
+    {"nodes": [
+      {"feature": 0, "threshold": 30, "left": 1, "right": 2},
+      {"leaf": true, "class": 1},
+      {"leaf": true, "class": 0}
+    ]}
+  ]
+}`
+
+func TestProba(t *testing.T) {
+	f, err := Read(strings.NewReader(stumps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		row   []float64
+		proba float64
+		class int
+	}{
+		{[]float64{2, 0}, 0.9, 1},
+		{[]float64{2, 50}, 0.55, 1},
+		{[]float64{400, 0}, 0.4, 0},
+		{[]float64{400, 50}, 0.05, 0},
+	}
+	for _, tt := range tests {
+		if got := f.Proba(tt.row); got != tt.proba {
+			t.Errorf("%v: got proba %v, want %v", tt.row, got, tt.proba)
+		}
+		if got := f.Predict(tt.row); got != tt.class {
+			t.Errorf("%v: got class %d, want %d", tt.row, got, tt.class)
+		}
+	}
+	if got := f.ProbaMap(map[string]float64{"age": 2, "extra": 1}); got != 0.9 {
+		t.Errorf("got proba %v with followers missing, want 0.9", got)
+	}
+}
+
+func TestScoreShortRow(t *testing.T) {
+	f, err := Read(strings.NewReader(stumps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Score([]float64{2}); err == nil {
+		t.Error("expected an error for a row missing a feature")
+	}
+	if got, err := f.Score([]float64{2, 0}); err != nil || got != 0.9 {
+		t.Errorf("got %v, %v, want 0.9", got, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Proba to panic on a row missing a feature")
+		}
+	}()
+	f.Proba([]float64{2})
+}
+
+func TestValidate(t *testing.T) {
+	for name, replace := range map[string][2]string{
+		"format":        {`"gh-spam-forest"`, `"other"`},
+		"version":       {`"version": 2`, `"version": 3`},
+		"feature":       {`"feature": 1,`, `"feature": 2,`},
+		"children":      {`"threshold": 5, "left": 1`, `"threshold": 5, "left": 0`},
+		"proba":         {`"proba": 0.8`, `"proba": 1.5`},
+		"missing nodes": {`"trees": [`, `"trees": [{"nodes": []}, `},
+	} {
+		data := strings.Replace(stumps, replace[0], replace[1], 1)
+		if data == stumps {
+			t.Fatalf("%s: the replacement didn't apply", name)
+		}
+		if _, err := Read(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := Read(strings.NewReader(`{"format": "gh-spam-forest", "version": 2, "trees": []}`)); err == nil {
+		t.Error("expected an error for a forest without trees")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	f, err := Read(strings.NewReader(stumps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "forest.json")
+	if err := f.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b bytes.Buffer
+	f.Write(&a)
+	loaded.Write(&b)
+	if a.String() != b.String() {
+		t.Errorf("got %s after loading, want %s", b.String(), a.String())
+	}
+}
@@ -0,0 +1,238 @@
+package classify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sjwhitworth/golearn/ensemble"
+	"github.com/sjwhitworth/golearn/trees"
+)
+
+// Classifiers that can be trained
+const (
+	// ClassifierForest is a random forest; each tree sees Features random columns
+	ClassifierForest = "forest"
+	// ClassifierBagging is bagged decision trees that see every column
+	ClassifierBagging = "bagging"
+	// ClassifierBoosting is gradient-boosted regression trees
+	ClassifierBoosting = "boosting"
+)
+
+// Params are the hyperparameters of a classifier
+type Params struct {
+	Classifier string `json:"classifier" yaml:"classifier"`
+	Trees      int    `json:"trees" yaml:"trees"`
+	// Features is the number of columns each tree of a forest samples.
+	// golearn samples them once per tree rather than at each split.
+	Features int `json:"features" yaml:"features"`
+	// MaxDepth limits the depth of each tree. 0 is unlimited, or 3 for
+	// boosting.
+	MaxDepth int `json:"max_depth" yaml:"max_depth"`
+	// LearningRate scales each boosted tree. 0 is 0.1.
+	LearningRate float64 `json:"learning_rate,omitempty" yaml:"learning_rate"`
+}
+
+// DefaultParams are used to train a model that hasn't been tuned
+var DefaultParams = Params{
+	Classifier: ClassifierForest,
+	Trees:      61,
+	Features:   9,
+}
+
+func (p Params) String() string {
+	s := fmt.Sprintf("%s trees=%d", p.Classifier, p.Trees)
+	if p.Classifier == ClassifierForest {
+		s += fmt.Sprintf(" features-per-tree=%d", p.Features)
+	}
+	if p.MaxDepth > 0 {
+		s += fmt.Sprintf(" depth=%d", p.MaxDepth)
+	}
+	if p.LearningRate > 0 {
+		s += fmt.Sprintf(" rate=%g", p.LearningRate)
+	}
+	return s
+}
+
+// Validate checks the params can be fit on a dataset with the given columns
+func (p Params) Validate(cols []string) error {
+	switch p.Classifier {
+	case ClassifierForest, ClassifierBagging, ClassifierBoosting:
+	default:
+		return fmt.Errorf("Unknown classifier %q, expected forest, bagging or boosting", p.Classifier)
+	}
+	if p.Trees < 1 {
+		return fmt.Errorf("Invalid number of trees %d", p.Trees)
+	}
+	if p.Classifier == ClassifierForest && (p.Features < 1 || p.Features > len(cols)-1) {
+		return fmt.Errorf("Invalid number of features %d, the dataset has %d", p.Features, len(cols)-1)
+	}
+	if p.MaxDepth < 0 {
+		return fmt.Errorf("Invalid max depth %d", p.MaxDepth)
+	}
+	if p.LearningRate < 0 || p.LearningRate > 1 {
+		return fmt.Errorf("Invalid learning rate %g", p.LearningRate)
+	}
+	return nil
+}
+
+// ModelMeta describes how a model was trained. It is saved next to the model.
+type ModelMeta struct {
+	Params
+	Kind      string    `json:"kind"`
+	Cols      []string  `json:"cols"`
+	Seed      int64     `json:"seed"`
+	Balance   string    `json:"balance,omitempty"`
+	TrainedAt time.Time `json:"trained_at"`
+	// Cross-validated F1 score of the spam class, set by tuning
+	Folds int     `json:"folds,omitempty"`
+	Score float64 `json:"cv_f1,omitempty"`
+	// Calibration of the model's scores, if it was calibrated
+	Calibration *Calibration `json:"calibration,omitempty"`
+	// OperatingPoint is the spam threshold chosen for a min precision
+	OperatingPoint *OperatingPoint `json:"operating_point,omitempty"`
+}
+
+// MetaPath is the path of a model's metadata, e.g. data/cli-cli.json
+func MetaPath(modelPath string) string {
+	return strings.TrimSuffix(modelPath, filepath.Ext(modelPath)) + ".json"
+}
+
+// ReadMeta reads a model's metadata. Models trained before metadata
+// was saved get DefaultParams.
+func ReadMeta(modelPath string) (ModelMeta, error) {
+	meta := ModelMeta{Params: DefaultParams}
+	b, err := os.ReadFile(MetaPath(modelPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return meta, nil
+	} else if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return meta, fmt.Errorf("Invalid model metadata %s: %s", MetaPath(modelPath), err)
+	}
+	return meta, nil
+}
+
+// WriteMeta saves a model's metadata
+func WriteMeta(modelPath string, meta ModelMeta) error {
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetaPath(modelPath), append(b, '\n'), 0600)
+}
+
+// Model is a trained classifier
+type Model interface {
+	// Proba returns the spam probability of each row of a dataset
+	Proba(d *Dataset) ([]float64, error)
+	// Save writes the model to a file
+	Save(path string) error
+}
+
+// RandomForest is a golearn forest of ID3 trees
+type RandomForest struct {
+	*ensemble.RandomForest
+	// Features are the columns the forest was trained on, in order. golearn
+	// reads columns by position, so datasets are matched to them by name.
+	// Nil skips the check, for models trained before it was saved.
+	Features []string
+}
+
+// Proba returns the fraction of trees voting spam for each row of a dataset
+func (rf RandomForest) Proba(d *Dataset) ([]float64, error) {
+	if rf.Features != nil {
+		var err error
+		if d, err = d.Select(rf.Features); err != nil {
+			return nil, err
+		}
+	}
+	return SpamProba(rf.RandomForest, d.Instances())
+}
+
+// Fit trains a classifier on a dataset, making random choices with rng
+func Fit(d *Dataset, p Params, rng *rand.Rand) (Model, error) {
+	train, valid := HoldOutValidation(d, p, rng)
+	return FitValidated(train, valid, p, rng)
+}
+
+// FitValidated trains a classifier on a dataset, stopping boosting early on
+// the rows held out by HoldOutValidation
+func FitValidated(d, valid *Dataset, p Params, rng *rand.Rand) (Model, error) {
+	if err := p.Validate(d.Cols); err != nil {
+		return nil, err
+	}
+	if p.Classifier == ClassifierBoosting {
+		return FitBoosted(d, valid, p)
+	}
+	// golearn samples with the global source, so seed it from rng. It fits
+	// trees concurrently from that shared source, so forests aren't
+	// deterministic even with a seed.
+	rand.Seed(rng.Int63())
+	features := p.Features
+	if p.Classifier == ClassifierBagging {
+		features = len(d.Cols) - 1
+	}
+
+	forest := ensemble.NewRandomForest(p.Trees, features)
+	if err := forest.Fit(d.Instances()); err != nil {
+		return nil, err
+	}
+	if p.MaxDepth > 0 {
+		for _, model := range forest.Model.Models {
+			limitDepth(model.(*trees.ID3DecisionTree).Root, p.MaxDepth)
+		}
+	}
+	return RandomForest{forest, d.Cols[:len(d.Cols)-1]}, nil
+}
+
+// LoadModel loads a model and its metadata. A calibrated model's scores
+// are calibrated.
+func LoadModel(modelPath string) (Model, ModelMeta, error) {
+	meta, err := ReadMeta(modelPath)
+	if err != nil {
+		return nil, meta, err
+	}
+	var model Model
+	if meta.Classifier == ClassifierBoosting {
+		if model, err = LoadBoosted(modelPath); err != nil {
+			return nil, meta, err
+		}
+	} else {
+		forest := ensemble.NewRandomForest(meta.Trees, meta.Features)
+		if err := forest.Load(modelPath); err != nil {
+			return nil, meta, err
+		}
+		model = RandomForest{RandomForest: forest}
+		if len(meta.Cols) > 0 {
+			model = RandomForest{forest, meta.Cols[:len(meta.Cols)-1]}
+		}
+	}
+	if meta.Calibration != nil {
+		model = Calibrated{model, meta.Calibration}
+	}
+	return model, meta, nil
+}
+
+// limitDepth turns nodes deeper than depth into leaves, which predict
+// the majority class of their training rows
+func limitDepth(node *trees.DecisionTreeNode, depth int) {
+	if node == nil || node.Children == nil {
+		return
+	}
+	if depth == 0 {
+		node.Type = trees.LeafNode
+		node.Children = nil
+		node.SplitRule = &trees.DecisionTreeRule{}
+		return
+	}
+	for _, child := range node.Children {
+		limitDepth(child, depth-1)
+	}
+}
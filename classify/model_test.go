@@ -0,0 +1,77 @@
+package classify
+
+import (
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParamsValidate(t *testing.T) {
+	cols := []string{"a", "b", "is_spam"}
+	for _, p := range []Params{
+		{Classifier: "svm", Trees: 1},
+		{Classifier: ClassifierForest, Trees: 0, Features: 1},
+		{Classifier: ClassifierForest, Trees: 1, Features: 3},
+		{Classifier: ClassifierBagging, Trees: 1, MaxDepth: -1},
+		{Classifier: ClassifierBoosting, Trees: 1, LearningRate: 2},
+	} {
+		if err := p.Validate(cols); err == nil {
+			t.Errorf("%+v: expected an error", p)
+		}
+	}
+	if err := (Params{Classifier: ClassifierForest, Trees: 1, Features: 2}).Validate(cols); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	train := syntheticDataset(200, rng)
+	path := filepath.Join(t.TempDir(), "model.gob")
+
+	for _, p := range []Params{
+		{Classifier: ClassifierForest, Trees: 5, Features: 2},
+		{Classifier: ClassifierBoosting, Trees: 5, MaxDepth: 2},
+	} {
+		model, err := Fit(train, p, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := model.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteMeta(path, ModelMeta{Params: p, Cols: train.Cols}); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, meta, err := LoadModel(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Params != p {
+			t.Errorf("got params %v, want %v", meta.Params, p)
+		}
+		want, _ := model.Proba(train)
+		got, err := loaded.Proba(train)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: the loaded model's scores differ", p)
+		}
+
+		// columns are matched by name, so their order doesn't matter
+		reordered, err := train.Select([]string{"body_len", "template_sim", "followers", "age"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := loaded.Proba(reordered); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v for reordered columns, want the same scores", p, err)
+		}
+		missing, _ := train.Select([]string{"age", "followers", "template_sim"})
+		if _, err := loaded.Proba(missing); err == nil {
+			t.Errorf("%s: expected an error for a missing column", p)
+		}
+	}
+}
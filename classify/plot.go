@@ -0,0 +1,111 @@
+package classify
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Size of ASCII plots in characters
+const (
+	plotWidth  = 50
+	plotHeight = 15
+)
+
+// Size of SVG plots in pixels, and the margin for the axis labels
+const (
+	svgSize   = 240
+	svgMargin = 40
+)
+
+// asciiPlot draws a curve with x and y in [0, 1]
+func asciiPlot(xs, ys []float64, xLabel, yLabel string) string {
+	grid := make([][]byte, plotHeight)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", plotWidth))
+	}
+	cell := func(x, y float64) (int, int) {
+		col := int(x*float64(plotWidth-1) + 0.5)
+		row := plotHeight - 1 - int(y*float64(plotHeight-1)+0.5)
+		return row, col
+	}
+	for i := range xs {
+		row, col := cell(xs[i], ys[i])
+		grid[row][col] = '*'
+		if i == 0 {
+			continue
+		}
+		// fill in between points so steep segments stay connected
+		prevRow, prevCol := cell(xs[i-1], ys[i-1])
+		steps := abs(row-prevRow) + abs(col-prevCol)
+		for s := 1; s < steps; s++ {
+			r := prevRow + (row-prevRow)*s/steps
+			c := prevCol + (col-prevCol)*s/steps
+			if grid[r][c] == ' ' {
+				grid[r][c] = '.'
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", yLabel)
+	for i, line := range grid {
+		axis := "    |"
+		switch i {
+		case 0:
+			axis = "1.0 |"
+		case plotHeight - 1:
+			axis = "0.0 |"
+		}
+		fmt.Fprintf(&b, "%s%s\n", axis, strings.TrimRight(string(line), " "))
+	}
+	fmt.Fprintf(&b, "    +%s\n", strings.Repeat("-", plotWidth))
+	fmt.Fprintf(&b, "    0%s1 %s\n", strings.Repeat(" ", plotWidth-2), xLabel)
+	return b.String()
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// svgPlot draws a curve with x and y in [0, 1] as an inline SVG
+func svgPlot(xs, ys []float64, xLabel, yLabel string) string {
+	size := svgSize + 2*svgMargin
+	points := make([]string, len(xs))
+	for i := range xs {
+		points[i] = fmt.Sprintf("%.1f,%.1f", svgMargin+xs[i]*svgSize, svgMargin+(1-ys[i])*svgSize)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-size="12">`+"\n", size, size)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#999"/>`+"\n", svgMargin, svgMargin, svgSize, svgSize)
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#d1242f" stroke-width="2"/>`+"\n", strings.Join(points, " "))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n", svgMargin+svgSize/2, size-10, html.EscapeString(xLabel))
+	fmt.Fprintf(&b, `<text x="12" y="%d" text-anchor="middle" transform="rotate(-90 12 %d)">%s</text>`+"\n", svgMargin+svgSize/2, svgMargin+svgSize/2, html.EscapeString(yLabel))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">0</text>`+"\n", svgMargin, svgMargin+svgSize+14)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">1</text>`+"\n", svgMargin+svgSize, svgMargin+svgSize+14)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">1</text>`+"\n", svgMargin-4, svgMargin+4)
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// prPoints returns the recall and precision of a PR curve, by recall
+func prPoints(curve []PRPoint) (xs, ys []float64) {
+	for _, p := range curve {
+		xs = append(xs, p.Recall)
+		ys = append(ys, p.Precision)
+	}
+	return xs, ys
+}
+
+// rocPoints returns the false and true positive rates of an ROC curve
+func rocPoints(curve []ROCPoint) (xs, ys []float64) {
+	for _, p := range curve {
+		xs = append(xs, p.FPR)
+		ys = append(ys, p.TPR)
+	}
+	return xs, ys
+}
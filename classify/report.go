@@ -0,0 +1,455 @@
+package classify
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReportSpec configures a benchmark of models and feature sets
+type ReportSpec struct {
+	Models      []ReportModel `yaml:"models"`
+	FeatureSets []FeatureSet  `yaml:"feature_sets"`
+	// TestSize is the fraction of each class held out for evaluation
+	TestSize float64 `yaml:"test_size"`
+	Seed     int64   `yaml:"seed"`
+	Balance  string  `yaml:"balance"`
+}
+
+// ReportModel is a named classifier to benchmark
+type ReportModel struct {
+	Name   string `yaml:"name"`
+	Params `yaml:",inline"`
+}
+
+// FeatureSet is a named subset of the dataset's features. With Include
+// only those features are used, otherwise all but Exclude.
+type FeatureSet struct {
+	Name    string   `yaml:"name"`
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+// DefaultReportSpec compares the classifiers on all features
+var DefaultReportSpec = ReportSpec{
+	Models: []ReportModel{
+		{Name: ClassifierForest, Params: DefaultParams},
+		{Name: ClassifierBagging, Params: Params{Classifier: ClassifierBagging, Trees: DefaultParams.Trees}},
+		{Name: ClassifierBoosting, Params: Params{Classifier: ClassifierBoosting, Trees: 200}},
+	},
+	FeatureSets: []FeatureSet{{Name: "all"}},
+	TestSize:    0.25,
+	Seed:        42,
+	Balance:     BalanceNone,
+}
+
+// LoadReportSpec reads a report spec, with defaults for missing settings
+func LoadReportSpec(path string) (ReportSpec, error) {
+	spec := ReportSpec{TestSize: DefaultReportSpec.TestSize, Seed: DefaultReportSpec.Seed, Balance: BalanceNone}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return spec, fmt.Errorf("Invalid report spec %s: %s", path, err)
+	}
+	if len(spec.Models) == 0 {
+		spec.Models = DefaultReportSpec.Models
+	}
+	for i, m := range spec.Models {
+		if m.Name == "" {
+			spec.Models[i].Name = m.Params.String()
+		}
+	}
+	if len(spec.FeatureSets) == 0 {
+		spec.FeatureSets = DefaultReportSpec.FeatureSets
+	}
+	return spec, nil
+}
+
+// Select returns a dataset with only the given features and the class
+func (d *Dataset) Select(features []string) (*Dataset, error) {
+	cols, err := featureIndex(append(append([]string{}, features...), d.Cols[len(d.Cols)-1]), d.Cols)
+	if err != nil {
+		return nil, err
+	}
+	out := &Dataset{Cols: make([]string, len(cols))}
+	for i, c := range cols {
+		out.Cols[i] = d.Cols[c]
+	}
+	for r, row := range d.Rows {
+		selected := make([]float64, len(cols))
+		for i, c := range cols {
+			selected[i] = row[c]
+		}
+		out.Append(selected, d.Meta[r])
+	}
+	return out, nil
+}
+
+// Features returns the features of the set among the columns
+func (fs FeatureSet) Features(cols []string) ([]string, error) {
+	features := cols[:len(cols)-1]
+	known := map[string]bool{}
+	for _, col := range features {
+		known[col] = true
+	}
+	for _, name := range append(append([]string{}, fs.Include...), fs.Exclude...) {
+		if !known[name] {
+			return nil, fmt.Errorf("feature set %s: unknown feature %s", fs.Name, name)
+		}
+	}
+	if len(fs.Include) > 0 {
+		return fs.Include, nil
+	}
+	excluded := map[string]bool{}
+	for _, name := range fs.Exclude {
+		excluded[name] = true
+	}
+	out := []string{}
+	for _, col := range features {
+		if !excluded[col] {
+			out = append(out, col)
+		}
+	}
+	return out, nil
+}
+
+// ROCPoint is the false and true positive rates of predicting spam for
+// rows with a spam probability of at least Threshold
+type ROCPoint struct {
+	Threshold float64
+	FPR, TPR  float64
+}
+
+// ROCCurve returns the ROC curve from (0, 0) to (1, 1), with a point for
+// each distinct probability
+func ROCCurve(d *Dataset, probs []float64) []ROCPoint {
+	order := make([]int, len(probs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return probs[order[i]] > probs[order[j]]
+	})
+	nonSpam, spam := d.ClassCounts()
+
+	curve := []ROCPoint{{Threshold: 1, FPR: 0, TPR: 0}}
+	tp, fp := 0.0, 0.0
+	for n, i := range order {
+		if d.Label(i) == 1 {
+			tp++
+		} else {
+			fp++
+		}
+		if n+1 < len(order) && probs[order[n+1]] == probs[i] {
+			continue
+		}
+		point := ROCPoint{Threshold: probs[i]}
+		if nonSpam > 0 {
+			point.FPR = fp / float64(nonSpam)
+		}
+		if spam > 0 {
+			point.TPR = tp / float64(spam)
+		}
+		curve = append(curve, point)
+	}
+	return curve
+}
+
+// AUC is the area under an ROC curve
+func AUC(curve []ROCPoint) float64 {
+	area := 0.0
+	for i := 1; i < len(curve); i++ {
+		area += (curve[i].FPR - curve[i-1].FPR) * (curve[i].TPR + curve[i-1].TPR) / 2
+	}
+	return area
+}
+
+// Confusion counts predictions at a threshold
+type Confusion struct {
+	TP, FP, TN, FN int
+}
+
+// NewConfusion counts the predictions of rows with a spam probability of
+// at least threshold as spam
+func NewConfusion(d *Dataset, probs []float64, threshold float64) Confusion {
+	var c Confusion
+	for i, prob := range probs {
+		switch predicted, actual := prob >= threshold, d.Label(i) == 1; {
+		case predicted && actual:
+			c.TP++
+		case predicted:
+			c.FP++
+		case actual:
+			c.FN++
+		default:
+			c.TN++
+		}
+	}
+	return c
+}
+
+// Precision of the spam class
+func (c Confusion) Precision() float64 {
+	if c.TP+c.FP == 0 {
+		return 0
+	}
+	return float64(c.TP) / float64(c.TP+c.FP)
+}
+
+// Recall of the spam class
+func (c Confusion) Recall() float64 {
+	if c.TP+c.FN == 0 {
+		return 0
+	}
+	return float64(c.TP) / float64(c.TP+c.FN)
+}
+
+// F1 of the spam class
+func (c Confusion) F1() float64 {
+	if c.TP == 0 {
+		return 0
+	}
+	return 2 * float64(c.TP) / float64(2*c.TP+c.FP+c.FN)
+}
+
+// Importance is how much a feature matters to a model
+type Importance struct {
+	Feature string
+	Value   float64
+}
+
+// PermutationImportance is the drop in AUC on a dataset when each feature
+// is shuffled, most important first
+func PermutationImportance(m Model, d *Dataset, rng *rand.Rand) ([]Importance, error) {
+	probs, err := m.Proba(d)
+	if err != nil {
+		return nil, err
+	}
+	base := AUC(ROCCurve(d, probs))
+
+	imp := []Importance{}
+	for f, col := range d.Cols[:len(d.Cols)-1] {
+		shuffled := &Dataset{Cols: d.Cols, Meta: d.Meta}
+		perm := rng.Perm(len(d.Rows))
+		for r, row := range d.Rows {
+			copied := append([]float64{}, row...)
+			copied[f] = d.Rows[perm[r]][f]
+			shuffled.Rows = append(shuffled.Rows, copied)
+		}
+		probs, err := m.Proba(shuffled)
+		if err != nil {
+			return nil, err
+		}
+		imp = append(imp, Importance{Feature: col, Value: base - AUC(ROCCurve(shuffled, probs))})
+	}
+	sort.SliceStable(imp, func(i, j int) bool {
+		return imp[i].Value > imp[j].Value
+	})
+	return imp, nil
+}
+
+// ReportResult is the evaluation of a model on a feature set
+type ReportResult struct {
+	Model      string
+	FeatureSet string
+	Params     Params
+	Confusion  Confusion
+	AUC        float64
+	PR         []PRPoint
+	ROC        []ROCPoint
+	Importance []Importance
+}
+
+// Report is a benchmark of models on the same train/test split
+type Report struct {
+	Spec    ReportSpec
+	Train   string
+	Test    string
+	Results []ReportResult
+}
+
+// RunReport trains and evaluates each model on each feature set. Every
+// model sees the same stratified split, and is fit with the spec's seed.
+func RunReport(d *Dataset, spec ReportSpec) (*Report, error) {
+	if spec.TestSize <= 0 || spec.TestSize >= 1 {
+		return nil, fmt.Errorf("test size must be between 0 and 1")
+	}
+	train, test := Split(d, spec.TestSize, rand.New(rand.NewSource(spec.Seed)))
+	if nonSpam, spam := test.ClassCounts(); nonSpam == 0 || spam == 0 {
+		return nil, fmt.Errorf("Need test rows of both classes, the dataset has %s", d.Balance())
+	}
+	report := &Report{Spec: spec, Train: train.Balance(), Test: test.Balance()}
+
+	for _, fs := range spec.FeatureSets {
+		features, err := fs.Features(d.Cols)
+		if err != nil {
+			return nil, err
+		}
+		if len(features) == 0 {
+			return nil, fmt.Errorf("feature set %s has no features", fs.Name)
+		}
+		fsTrain, err := train.Select(features)
+		if err != nil {
+			return nil, err
+		}
+		fsTest, err := test.Select(features)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range spec.Models {
+			params := m.Params
+			if params.Classifier == "" {
+				params.Classifier = DefaultParams.Classifier
+			}
+			if params.Trees == 0 {
+				params.Trees = DefaultParams.Trees
+			}
+			if params.Classifier != ClassifierForest {
+				params.Features = 0
+			} else if params.Features == 0 || params.Features > len(features) {
+				params.Features = DefaultParams.Features
+				if params.Features > len(features) {
+					params.Features = len(features)
+				}
+			}
+			rng := rand.New(rand.NewSource(spec.Seed))
+
+			fit, valid := HoldOutValidation(fsTrain, params, rng)
+			balanced, err := Rebalance(WeightConfidence(fit), spec.Balance, rng)
+			if err != nil {
+				return nil, err
+			}
+			model, err := FitValidated(balanced, valid, params, rng)
+			if err != nil {
+				return nil, fmt.Errorf("%s on %s: %w", m.Name, fs.Name, err)
+			}
+			probs, err := model.Proba(fsTest)
+			if err != nil {
+				return nil, err
+			}
+			imp, err := PermutationImportance(model, fsTest, rng)
+			if err != nil {
+				return nil, err
+			}
+			roc := ROCCurve(fsTest, probs)
+			report.Results = append(report.Results, ReportResult{
+				Model:      m.Name,
+				FeatureSet: fs.Name,
+				Params:     params,
+				Confusion:  NewConfusion(fsTest, probs, 0.5),
+				AUC:        AUC(roc),
+				PR:         PRCurve(fsTest, probs),
+				ROC:        roc,
+				Importance: imp,
+			})
+		}
+	}
+	return report, nil
+}
+
+func (r ReportResult) title() string {
+	return fmt.Sprintf("%s on %s", r.Model, r.FeatureSet)
+}
+
+// Report formats
+const (
+	ReportMarkdown = "markdown"
+	ReportHTML     = "html"
+)
+
+// Write writes the report in a format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportMarkdown:
+		_, err := io.WriteString(w, r.Markdown())
+		return err
+	case ReportHTML:
+		_, err := io.WriteString(w, r.HTML())
+		return err
+	}
+	return fmt.Errorf("Unknown report format %q, expected markdown or html", format)
+}
+
+func (r *Report) summary() string {
+	return fmt.Sprintf("Trained on %s and tested on %s, with seed %d and balance %s. Predictions are spam at a probability of at least 0.5.",
+		r.Train, r.Test, r.Spec.Seed, r.Spec.Balance)
+}
+
+// Markdown renders the report with ASCII plots
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Classifier report\n\n%s\n\n", r.summary())
+	b.WriteString("| Feature set | Model | Params | Precision | Recall | F1 | AUC |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, res := range r.Results {
+		c := res.Confusion
+		fmt.Fprintf(&b, "| %s | %s | %s | %.3f | %.3f | %.3f | %.3f |\n",
+			res.FeatureSet, res.Model, res.Params, c.Precision(), c.Recall(), c.F1(), res.AUC)
+	}
+
+	for _, res := range r.Results {
+		c := res.Confusion
+		fmt.Fprintf(&b, "\n## %s\n\n", res.title())
+		b.WriteString("| | Predicted spam | Predicted not spam |\n|---|---|---|\n")
+		fmt.Fprintf(&b, "| **Spam** | %d | %d |\n", c.TP, c.FN)
+		fmt.Fprintf(&b, "| **Not spam** | %d | %d |\n", c.FP, c.TN)
+
+		xs, ys := prPoints(res.PR)
+		fmt.Fprintf(&b, "\nPrecision/recall curve\n\n```\n%s```\n", asciiPlot(xs, ys, "recall", "precision"))
+		xs, ys = rocPoints(res.ROC)
+		fmt.Fprintf(&b, "\nROC curve, AUC %.3f\n\n```\n%s```\n", res.AUC, asciiPlot(xs, ys, "false positive rate", "true positive rate"))
+
+		b.WriteString("\n| Feature | Importance |\n|---|---|\n")
+		for _, imp := range res.Importance {
+			fmt.Fprintf(&b, "| %s | %.4f |\n", imp.Feature, imp.Value)
+		}
+	}
+	b.WriteString("\nImportance is the drop in test AUC when the feature is shuffled.\n")
+	return b.String()
+}
+
+// HTML renders the report as a standalone page with SVG plots
+func (r *Report) HTML() string {
+	esc := html.EscapeString
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Classifier report</title>\n")
+	b.WriteString("<style>body{font-family:sans-serif} table{border-collapse:collapse} td,th{border:1px solid #ccc;padding:4px 8px}</style>\n")
+	fmt.Fprintf(&b, "</head>\n<body>\n<h1>Classifier report</h1>\n<p>%s</p>\n", esc(r.summary()))
+	b.WriteString("<table>\n<tr><th>Feature set</th><th>Model</th><th>Params</th><th>Precision</th><th>Recall</th><th>F1</th><th>AUC</th></tr>\n")
+	for _, res := range r.Results {
+		c := res.Confusion
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%.3f</td><td>%.3f</td><td>%.3f</td><td>%.3f</td></tr>\n",
+			esc(res.FeatureSet), esc(res.Model), esc(res.Params.String()), c.Precision(), c.Recall(), c.F1(), res.AUC)
+	}
+	b.WriteString("</table>\n")
+
+	for _, res := range r.Results {
+		c := res.Confusion
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", esc(res.title()))
+		b.WriteString("<table>\n<tr><th></th><th>Predicted spam</th><th>Predicted not spam</th></tr>\n")
+		fmt.Fprintf(&b, "<tr><th>Spam</th><td>%d</td><td>%d</td></tr>\n", c.TP, c.FN)
+		fmt.Fprintf(&b, "<tr><th>Not spam</th><td>%d</td><td>%d</td></tr>\n</table>\n", c.FP, c.TN)
+
+		xs, ys := prPoints(res.PR)
+		fmt.Fprintf(&b, "<h3>Precision/recall curve</h3>\n%s", svgPlot(xs, ys, "recall", "precision"))
+		xs, ys = rocPoints(res.ROC)
+		fmt.Fprintf(&b, "<h3>ROC curve, AUC %.3f</h3>\n%s", res.AUC, svgPlot(xs, ys, "false positive rate", "true positive rate"))
+
+		b.WriteString("<table>\n<tr><th>Feature</th><th>Importance</th></tr>\n")
+		for _, imp := range res.Importance {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%.4f</td></tr>\n", esc(imp.Feature), imp.Value)
+		}
+		b.WriteString("</table>\n")
+	}
+	b.WriteString("<p>Importance is the drop in test AUC when the feature is shuffled.</p>\n</body>\n</html>\n")
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package classify
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestROCCurve(t *testing.T) {
+	d := &Dataset{Cols: []string{"is_spam"}}
+	for _, label := range []float64{1, 1, 0, 1, 0, 0} {
+		d.Append([]float64{label}, RowMeta{})
+	}
+
+	perfect := ROCCurve(d, []float64{0.9, 0.8, 0.3, 0.7, 0.2, 0.1})
+	if auc := AUC(perfect); auc != 1 {
+		t.Errorf("got AUC %v for a perfect ranking, want 1", auc)
+	}
+	if last := perfect[len(perfect)-1]; last.FPR != 1 || last.TPR != 1 {
+		t.Errorf("got last point %+v, want (1, 1)", last)
+	}
+
+	tied := ROCCurve(d, []float64{0.5, 0.5, 0.5, 0.5, 0.5, 0.5})
+	if len(tied) != 2 || AUC(tied) != 0.5 {
+		t.Errorf("got %d points with AUC %v for tied scores, want 2 with AUC 0.5", len(tied), AUC(tied))
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	d := syntheticDataset(300, rand.New(rand.NewSource(1)))
+	spec := ReportSpec{
+		Models: []ReportModel{
+			{Name: "forest", Params: Params{Classifier: ClassifierForest, Trees: 10}},
+			{Name: "boosting", Params: Params{Classifier: ClassifierBoosting, Trees: 50}},
+		},
+		FeatureSets: []FeatureSet{{Name: "all"}, {Name: "no-age", Exclude: []string{"age"}}},
+		TestSize:    0.3,
+		Seed:        1,
+	}
+
+	report, err := RunReport(d, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 4 {
+		t.Fatalf("got %d results, want one per model and feature set", len(report.Results))
+	}
+	res := report.Results[1]
+	if res.AUC < 0.9 {
+		t.Errorf("got boosting AUC %.3f on all features, want at least 0.9", res.AUC)
+	}
+	if res.Importance[0].Feature != "age" {
+		t.Errorf("got importance %v, want age first", res.Importance)
+	}
+	for _, imp := range report.Results[3].Importance {
+		if imp.Feature == "age" {
+			t.Error("got importance of age, which the no-age feature set excludes")
+		}
+	}
+
+	// golearn fits forests' trees concurrently, so only boosting is exact
+	again, err := RunReport(d, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{1, 3} {
+		if !reflect.DeepEqual(again.Results[i], report.Results[i]) {
+			t.Errorf("got a different %s with the same seed", report.Results[i].title())
+		}
+	}
+
+	md := report.Markdown()
+	if !strings.Contains(md, "## boosting on no-age") || !strings.Contains(md, "ROC curve") {
+		t.Errorf("markdown is missing a section:\n%s", md)
+	}
+	if page := report.HTML(); strings.Count(page, "<svg") != 8 {
+		t.Errorf("got %d SVG plots, want a PR and ROC curve per result", strings.Count(page, "<svg"))
+	}
+
+	spec.FeatureSets = []FeatureSet{{Name: "bad", Include: []string{"stars"}}}
+	if _, err := RunReport(d, spec); err == nil {
+		t.Error("expected an error for an unknown feature")
+	}
+}
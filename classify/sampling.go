@@ -0,0 +1,165 @@
+package classify
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Ways to balance the classes of a training set
+const (
+	BalanceNone        = "none"
+	BalanceWeight      = "weight"
+	BalanceUndersample = "undersample"
+	BalanceSMOTE       = "smote"
+)
+
+// smoteNeighbors is the number of nearest neighbors SMOTE interpolates between
+const smoteNeighbors = 5
+
+// Rebalance returns a training set with balanced classes
+func Rebalance(d *Dataset, method string, rng *rand.Rand) (*Dataset, error) {
+	switch method {
+	case "", BalanceNone:
+		return d, nil
+	case BalanceWeight:
+		return Weight(d), nil
+	case BalanceUndersample:
+		return Undersample(d, rng), nil
+	case BalanceSMOTE:
+		return SMOTE(d, smoteNeighbors, rng), nil
+	}
+	return nil, fmt.Errorf("Unknown balance method %q, expected none, weight, undersample or smote", method)
+}
+
+// splitClasses returns the row indices of the minority and majority class
+func splitClasses(d *Dataset) (minority, majority []int) {
+	nonSpam, spam := []int{}, []int{}
+	for i := range d.Rows {
+		if d.Label(i) == 1 {
+			spam = append(spam, i)
+		} else {
+			nonSpam = append(nonSpam, i)
+		}
+	}
+	if len(spam) <= len(nonSpam) {
+		return spam, nonSpam
+	}
+	return nonSpam, spam
+}
+
+func (d *Dataset) subset(rows []int) *Dataset {
+	out := &Dataset{Cols: d.Cols}
+	for _, i := range rows {
+		out.Append(d.Rows[i], d.Meta[i])
+	}
+	return out
+}
+
+// Weight gives the classes equal total weight by repeating each minority
+// row, since golearn's trees don't take row weights
+func Weight(d *Dataset) *Dataset {
+	minority, majority := splitClasses(d)
+	if len(minority) == 0 {
+		return d
+	}
+
+	weight := int(math.Round(float64(len(majority)) / float64(len(minority))))
+	rows := append([]int{}, majority...)
+	for _, i := range minority {
+		for w := 0; w < weight; w++ {
+			rows = append(rows, i)
+		}
+	}
+	sort.Ints(rows)
+	return d.subset(rows)
+}
+
+// Undersample randomly drops majority rows until the classes are equal
+func Undersample(d *Dataset, rng *rand.Rand) *Dataset {
+	minority, majority := splitClasses(d)
+	rng.Shuffle(len(majority), func(i, j int) {
+		majority[i], majority[j] = majority[j], majority[i]
+	})
+
+	rows := append(minority, majority[:len(minority)]...)
+	sort.Ints(rows)
+	return d.subset(rows)
+}
+
+// SMOTE adds synthetic minority rows until the classes are equal. Each is
+// interpolated between a minority row and one of its k nearest minority
+// neighbors; int columns are rounded.
+func SMOTE(d *Dataset, k int, rng *rand.Rand) *Dataset {
+	minority, majority := splitClasses(d)
+	if len(minority) < 2 {
+		return d
+	}
+	if k > len(minority)-1 {
+		k = len(minority) - 1
+	}
+
+	scale := colRanges(d)
+	neighbors := make([][]int, len(minority))
+	for a, i := range minority {
+		others := []int{}
+		for _, j := range minority {
+			if j != i {
+				others = append(others, j)
+			}
+		}
+		sort.Slice(others, func(x, y int) bool {
+			return scaledDist(d.Rows[i], d.Rows[others[x]], scale) < scaledDist(d.Rows[i], d.Rows[others[y]], scale)
+		})
+		neighbors[a] = others[:k]
+	}
+
+	out := &Dataset{
+		Cols: d.Cols,
+		Rows: append([][]float64{}, d.Rows...),
+		Meta: append([]RowMeta{}, d.Meta...),
+	}
+	for n := len(minority); n < len(majority); n++ {
+		a := rng.Intn(len(minority))
+		x := d.Rows[minority[a]]
+		y := d.Rows[neighbors[a][rng.Intn(k)]]
+		gap := rng.Float64()
+
+		row := make([]float64, len(x))
+		for c := range x {
+			row[c] = x[c] + gap*(y[c]-x[c])
+			if floatCols[d.Cols[c]] == 0 {
+				row[c] = math.Round(row[c])
+			}
+		}
+		out.Append(row, RowMeta{})
+	}
+	return out
+}
+
+// colRanges is the range of each feature column, for scaling distances
+func colRanges(d *Dataset) []float64 {
+	ranges := make([]float64, len(d.Cols)-1)
+	for c := range ranges {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, row := range d.Rows {
+			lo = math.Min(lo, row[c])
+			hi = math.Max(hi, row[c])
+		}
+		ranges[c] = hi - lo
+	}
+	return ranges
+}
+
+func scaledDist(a, b, scale []float64) float64 {
+	dist := 0.0
+	for c, r := range scale {
+		if r == 0 {
+			continue
+		}
+		diff := (a[c] - b[c]) / r
+		dist += diff * diff
+	}
+	return dist
+}
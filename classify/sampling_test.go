@@ -0,0 +1,73 @@
+package classify
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRebalance(t *testing.T) {
+	d := syntheticDataset(300, rand.New(rand.NewSource(1)))
+	nonSpam, spam := d.ClassCounts()
+
+	for _, method := range []string{BalanceWeight, BalanceUndersample, BalanceSMOTE} {
+		out, err := Rebalance(d, method, rand.New(rand.NewSource(2)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotNonSpam, gotSpam := out.ClassCounts()
+		if diff := gotNonSpam - gotSpam; diff < -spam || diff > spam {
+			t.Errorf("%s: got %d non-spam and %d spam rows, want about equal", method, gotNonSpam, gotSpam)
+		}
+		if method == BalanceUndersample && (gotSpam != spam || gotNonSpam != spam) {
+			t.Errorf("undersample: got %d/%d rows, want %d of each", gotNonSpam, gotSpam, spam)
+		}
+		if method == BalanceSMOTE && (gotNonSpam != nonSpam || gotSpam != nonSpam) {
+			t.Errorf("smote: got %d/%d rows, want %d of each", gotNonSpam, gotSpam, nonSpam)
+		}
+
+		// the same seed samples the same rows
+		again, _ := Rebalance(d, method, rand.New(rand.NewSource(2)))
+		if !reflect.DeepEqual(out.Rows, again.Rows) {
+			t.Errorf("%s: expected the same rows for the same seed", method)
+		}
+	}
+
+	if _, err := Rebalance(d, "oversample", rand.New(rand.NewSource(1))); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+}
+
+func TestSMOTERoundsIntColumns(t *testing.T) {
+	d := syntheticDataset(100, rand.New(rand.NewSource(1)))
+	out := SMOTE(d, smoteNeighbors, rand.New(rand.NewSource(1)))
+	for _, row := range out.Rows[len(d.Rows):] {
+		if row[0] != float64(int(row[0])) || row[4] != 1 {
+			t.Fatalf("got synthetic row %v, want a whole age and the spam label", row)
+		}
+	}
+}
+
+func TestFitSeed(t *testing.T) {
+	d := syntheticDataset(200, rand.New(rand.NewSource(1)))
+	// golearn fits forests' trees concurrently, so only boosting is exact
+	for _, p := range []Params{
+		{Classifier: ClassifierBoosting, Trees: 5, MaxDepth: 2, LearningRate: 0.1},
+	} {
+		probs := [][]float64{}
+		for i := 0; i < 2; i++ {
+			model, err := Fit(d, p, rand.New(rand.NewSource(3)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := model.Proba(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			probs = append(probs, got)
+		}
+		if !reflect.DeepEqual(probs[0], probs[1]) {
+			t.Errorf("%s: expected the same model for the same seed", p)
+		}
+	}
+}
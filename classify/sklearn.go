@@ -0,0 +1,116 @@
+package classify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/meiji163/gh-spam/classify/forest"
+)
+
+// SklearnFormat identifies trees exported by script/sklearn_export.py
+const SklearnFormat = "sklearn-trees"
+
+// sklearnModel is a scikit-learn DecisionTreeClassifier or
+// RandomForestClassifier, as the arrays of each estimator's tree_
+type sklearnModel struct {
+	Format       string        `json:"format"`
+	FeatureNames []string      `json:"feature_names"`
+	Classes      []int         `json:"classes"`
+	Trees        []sklearnTree `json:"trees"`
+}
+
+type sklearnTree struct {
+	ChildrenLeft  []int       `json:"children_left"`
+	ChildrenRight []int       `json:"children_right"`
+	Feature       []int       `json:"feature"`
+	Threshold     []float64   `json:"threshold"`
+	Value         [][]float64 `json:"value"`
+}
+
+// ImportSklearn converts trees exported from scikit-learn to the forest
+// package's format. The model's features must be named, and must be
+// columns of the dataset columns cols. Like scikit-learn, the forest
+// averages the spam probability of each tree's leaf. scikit-learn compares
+// features as float32, so a row exactly at a threshold can score differently.
+func ImportSklearn(r io.Reader, cols []string) (*forest.Forest, error) {
+	var model sklearnModel
+	if err := json.NewDecoder(r).Decode(&model); err != nil {
+		return nil, err
+	}
+	if model.Format != SklearnFormat {
+		return nil, fmt.Errorf("unsupported model format %q, expected %s", model.Format, SklearnFormat)
+	}
+	if len(model.Classes) != 2 || model.Classes[0] != 0 || model.Classes[1] != 1 {
+		return nil, fmt.Errorf("model classes are %v, expected [0 1]", model.Classes)
+	}
+	if len(model.FeatureNames) == 0 {
+		return nil, fmt.Errorf("model has no feature names; fit it on a DataFrame of the dataset's columns")
+	}
+
+	known := map[string]bool{}
+	for _, col := range cols[:len(cols)-1] {
+		known[col] = true
+	}
+	seen := map[string]bool{}
+	for _, name := range model.FeatureNames {
+		if !known[name] {
+			return nil, fmt.Errorf("model feature %q is not a dataset column", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("model feature %q is repeated", name)
+		}
+		seen[name] = true
+	}
+
+	out := &forest.Forest{
+		Format:   forest.Format,
+		Version:  forest.Version,
+		Features: model.FeatureNames,
+	}
+	for t, st := range model.Trees {
+		n := len(st.ChildrenLeft)
+		if len(st.ChildrenRight) != n || len(st.Feature) != n || len(st.Threshold) != n || len(st.Value) != n {
+			return nil, fmt.Errorf("tree %d: arrays have different lengths", t)
+		}
+		tree := forest.Tree{Nodes: make([]forest.Node, n)}
+		for i := 0; i < n; i++ {
+			// scikit-learn marks leaves with child -1
+			if st.ChildrenLeft[i] < 0 {
+				value := st.Value[i]
+				if len(value) != 2 || value[0]+value[1] <= 0 {
+					return nil, fmt.Errorf("tree %d node %d: invalid class values %v", t, i, value)
+				}
+				proba := value[1] / (value[0] + value[1])
+				node := forest.Node{Leaf: true, Proba: &proba}
+				if proba > 0.5 {
+					node.Class = 1
+				}
+				tree.Nodes[i] = node
+				continue
+			}
+			tree.Nodes[i] = forest.Node{
+				Feature:   st.Feature[i],
+				Threshold: st.Threshold[i],
+				Left:      st.ChildrenLeft[i],
+				Right:     st.ChildrenRight[i],
+			}
+		}
+		out.Trees = append(out.Trees, tree)
+	}
+	if err := out.Validate(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LoadSklearn reads trees exported from scikit-learn from a file
+func LoadSklearn(path string, cols []string) (*forest.Forest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ImportSklearn(file, cols)
+}
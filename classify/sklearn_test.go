@@ -0,0 +1,44 @@
+package classify
+
+import (
+	"strings"
+	"testing"
+)
+
+// a depth 1 tree on age, as exported by script/sklearn_export.py
+const sklearnStump = `{
+  "format": "sklearn-trees",
+  "feature_names": ["followers", "age"],
+  "classes": [0, 1],
+  "trees": [{
+    "children_left": [1, -1, -1],
+    "children_right": [2, -1, -1],
+    "feature": [1, -2, -2],
+    "threshold": [30.5, -2, -2],
+    "value": [[0.5, 0.5], [0.2, 0.8], [0.9, 0.1]]
+  }]
+}`
+
+func TestImportSklearn(t *testing.T) {
+	cols := []string{"age", "followers", "template_sim", "is_spam"}
+	f, err := ImportSklearn(strings.NewReader(sklearnStump), cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Dataset{Cols: cols}
+	d.Append([]float64{2, 0, 0.1, 1}, RowMeta{})
+	d.Append([]float64{400, 0, 0.9, 0}, RowMeta{})
+	probs, err := ForestProba(f, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if probs[0] != 0.8 || probs[1] != 0.1 {
+		t.Errorf("got probabilities %v, want [0.8 0.1]", probs)
+	}
+
+	wrong := strings.Replace(sklearnStump, `"age"`, `"account_age"`, 1)
+	if _, err := ImportSklearn(strings.NewReader(wrong), cols); err == nil {
+		t.Error("expected an error importing a model with an unknown feature")
+	}
+}
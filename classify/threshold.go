@@ -0,0 +1,87 @@
+package classify
+
+import (
+	"sort"
+
+	"github.com/meiji163/gh-spam/spam"
+)
+
+// PRPoint is the precision and recall of the spam class when rows with a
+// spam probability of at least Threshold are predicted spam
+type PRPoint struct {
+	Threshold float64 `json:"threshold"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+}
+
+// OperatingPoint is the decision threshold chosen for a model
+type OperatingPoint struct {
+	PRPoint
+	// MinPrecision is the precision the threshold was chosen for
+	MinPrecision float64 `json:"min_precision"`
+	// Rows is the number of held-out rows it was chosen on
+	Rows int `json:"rows"`
+}
+
+// Apply sets a policy's spam threshold to the operating point's, lowering
+// the uncertain threshold to it if needed. A threshold the policy sets
+// itself is kept, and Apply returns false.
+func (op *OperatingPoint) Apply(cfg *spam.Config) bool {
+	if cfg.Thresholds.SpamSet {
+		return false
+	}
+	cfg.Thresholds.Spam = op.Threshold
+	if cfg.Thresholds.Uncertain > cfg.Thresholds.Spam {
+		cfg.Thresholds.Uncertain = cfg.Thresholds.Spam
+	}
+	return true
+}
+
+// PRCurve returns the precision and recall at each distinct probability,
+// from the highest threshold to the lowest
+func PRCurve(d *Dataset, probs []float64) []PRPoint {
+	order := make([]int, len(probs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return probs[order[i]] > probs[order[j]]
+	})
+	_, spam := d.ClassCounts()
+
+	curve := []PRPoint{}
+	tp, fp := 0.0, 0.0
+	for n, i := range order {
+		if d.Label(i) == 1 {
+			tp++
+		} else {
+			fp++
+		}
+		// rows with the same probability are predicted together
+		if n+1 < len(order) && probs[order[n+1]] == probs[i] {
+			continue
+		}
+		point := PRPoint{Threshold: probs[i], Precision: tp / (tp + fp)}
+		if spam > 0 {
+			point.Recall = tp / float64(spam)
+		}
+		curve = append(curve, point)
+	}
+	return curve
+}
+
+// ChooseThreshold returns the point of the curve with the highest recall
+// and at least minPrecision, preferring higher thresholds. It reports
+// false if no threshold is precise enough.
+func ChooseThreshold(curve []PRPoint, minPrecision float64) (PRPoint, bool) {
+	best := -1
+	for i, point := range curve {
+		if point.Precision >= minPrecision && (best < 0 || point.Recall > curve[best].Recall) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return PRPoint{}, false
+	}
+	return curve[best], true
+}
@@ -0,0 +1,61 @@
+package classify
+
+import (
+	"testing"
+
+	"github.com/meiji163/gh-spam/spam"
+)
+
+func TestChooseThreshold(t *testing.T) {
+	d := &Dataset{Cols: []string{"is_spam"}}
+	labels := []float64{1, 1, 0, 1, 1, 0, 0, 0}
+	probs := []float64{0.95, 0.9, 0.85, 0.8, 0.8, 0.6, 0.3, 0.1}
+	for _, label := range labels {
+		d.Append([]float64{label}, RowMeta{})
+	}
+
+	curve := PRCurve(d, probs)
+	if len(curve) != 7 {
+		t.Fatalf("got %d points, want one per distinct probability", len(curve))
+	}
+	if p := curve[3]; p.Threshold != 0.8 || p.Precision != 0.8 || p.Recall != 1 {
+		t.Errorf("got %+v at 0.8, want precision 0.8 and recall 1", p)
+	}
+
+	tests := []struct {
+		minPrecision float64
+		want         float64
+		ok           bool
+	}{
+		{1, 0.9, true},
+		{0.75, 0.8, true},
+		{0, 0.8, true},
+	}
+	for _, tt := range tests {
+		got, ok := ChooseThreshold(curve, tt.minPrecision)
+		if ok != tt.ok || got.Threshold != tt.want {
+			t.Errorf("min precision %v: got threshold %v (%v), want %v", tt.minPrecision, got.Threshold, ok, tt.want)
+		}
+	}
+
+	d.SetLabel(0, 0)
+	if _, ok := ChooseThreshold(PRCurve(d, probs), 1); ok {
+		t.Error("expected no threshold with precision 1 when the top row isn't spam")
+	}
+}
+
+func TestOperatingPointApply(t *testing.T) {
+	op := &OperatingPoint{PRPoint: PRPoint{Threshold: 0.4}, MinPrecision: 0.99}
+	cfg := spam.DefaultConfig()
+	if !op.Apply(&cfg) || cfg.Thresholds.Spam != 0.4 || cfg.Thresholds.Uncertain != 0.4 {
+		t.Errorf("got thresholds %+v, want the operating point's", cfg.Thresholds)
+	}
+
+	cfg, err := spam.ParseConfig([]byte("thresholds: {spam: 0.8}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Apply(&cfg) || cfg.Thresholds.Spam != 0.8 {
+		t.Errorf("got spam threshold %v, want the policy's 0.8 kept", cfg.Thresholds.Spam)
+	}
+}
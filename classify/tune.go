@@ -0,0 +1,164 @@
+package classify
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/sjwhitworth/golearn/evaluation"
+)
+
+// SearchSpace is the set of hyperparameters to search
+type SearchSpace struct {
+	Classifiers []string
+	Trees       []int
+	Features    []int
+	MaxDepth    []int
+}
+
+// DefaultSpace is searched by tune when no values are given
+var DefaultSpace = SearchSpace{
+	Classifiers: []string{ClassifierForest, ClassifierBagging, ClassifierBoosting},
+	Trees:       []int{31, 61, 101},
+	Features:    []int{5, 9, 15},
+	MaxDepth:    []int{0, 6, 12},
+}
+
+// Grid returns every combination of params that can be fit on the columns
+func (s SearchSpace) Grid(cols []string) []Params {
+	grid := []Params{}
+	seen := map[Params]bool{}
+	for _, classifier := range s.Classifiers {
+		for _, trees := range s.Trees {
+			for _, features := range s.Features {
+				for _, depth := range s.MaxDepth {
+					p := Params{Classifier: classifier, Trees: trees, Features: features, MaxDepth: depth}
+					if classifier != ClassifierForest {
+						p.Features = 0
+					}
+					if seen[p] || p.Validate(cols) != nil {
+						continue
+					}
+					seen[p] = true
+					grid = append(grid, p)
+				}
+			}
+		}
+	}
+	return grid
+}
+
+// Sample returns n random combinations from the grid, or the whole grid
+// if it has n or fewer
+func (s SearchSpace) Sample(cols []string, n int, rng *rand.Rand) []Params {
+	grid := s.Grid(cols)
+	if n >= len(grid) {
+		return grid
+	}
+	rng.Shuffle(len(grid), func(i, j int) {
+		grid[i], grid[j] = grid[j], grid[i]
+	})
+	return grid[:n]
+}
+
+// Folds splits the rows into k folds with the same class balance
+func Folds(d *Dataset, k int, rng *rand.Rand) [][]int {
+	folds := make([][]int, k)
+	nonSpam, spam := []int{}, []int{}
+	for i := range d.Rows {
+		if d.Label(i) == 1 {
+			spam = append(spam, i)
+		} else {
+			nonSpam = append(nonSpam, i)
+		}
+	}
+
+	n := 0
+	for _, rows := range [][]int{nonSpam, spam} {
+		rng.Shuffle(len(rows), func(i, j int) {
+			rows[i], rows[j] = rows[j], rows[i]
+		})
+		for _, i := range rows {
+			folds[n%k] = append(folds[n%k], i)
+			n++
+		}
+	}
+	return folds
+}
+
+// CrossValidate returns the mean F1 score of the spam class over k folds.
+// Only the training folds are weighted and rebalanced.
+func CrossValidate(d *Dataset, p Params, k int, balance string, rng *rand.Rand) (float64, error) {
+	nonSpam, spam := d.ClassCounts()
+	if k < 2 || nonSpam < k || spam < k {
+		return 0, fmt.Errorf("Need at least %d rows of each class for %d folds", k, k)
+	}
+
+	folds := Folds(d, k, rng)
+	total := 0.0
+	for f, test := range folds {
+		train := []int{}
+		for g, fold := range folds {
+			if g != f {
+				train = append(train, fold...)
+			}
+		}
+
+		trainSet, valid := HoldOutValidation(d.subset(train), p, rng)
+		trainSet, err := Rebalance(WeightConfidence(trainSet), balance, rng)
+		if err != nil {
+			return 0, err
+		}
+		model, err := FitValidated(trainSet, valid, p, rng)
+		if err != nil {
+			return 0, err
+		}
+
+		testSet := d.subset(test)
+		probs, err := model.Proba(testSet)
+		if err != nil {
+			return 0, err
+		}
+		total += F1(testSet, probs, 0.5)
+	}
+	return total / float64(k), nil
+}
+
+// F1 is the F1 score of the spam class when rows with a spam
+// probability of at least threshold are predicted spam
+func F1(d *Dataset, probs []float64, threshold float64) float64 {
+	tp, fp, fn := 0.0, 0.0, 0.0
+	for i, prob := range probs {
+		predicted, actual := prob >= threshold, d.Label(i) == 1
+		switch {
+		case predicted && actual:
+			tp++
+		case predicted:
+			fp++
+		case actual:
+			fn++
+		}
+	}
+	if tp == 0 {
+		return 0
+	}
+	return 2 * tp / (2*tp + fp + fn)
+}
+
+// ConfusionMatrix counts rows by actual and predicted class, "0" for not
+// spam and "1" for spam, when rows with a spam probability of at least
+// threshold are predicted spam
+func ConfusionMatrix(d *Dataset, probs []float64, threshold float64) evaluation.ConfusionMatrix {
+	cm := evaluation.ConfusionMatrix{
+		"0": {"0": 0, "1": 0},
+		"1": {"0": 0, "1": 0},
+	}
+	for i, prob := range probs {
+		predicted := "0"
+		if prob >= threshold {
+			predicted = "1"
+		}
+		cm[strconv.Itoa(d.Label(i))][predicted]++
+	}
+	return cm
+}
@@ -0,0 +1,80 @@
+package classify
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGrid(t *testing.T) {
+	space := SearchSpace{
+		Classifiers: []string{ClassifierForest, ClassifierBagging},
+		Trees:       []int{5},
+		Features:    []int{2, 9},
+		MaxDepth:    []int{0},
+	}
+	cols := []string{"a", "b", "c", "is_spam"}
+	grid := space.Grid(cols)
+	// forests with more features than the dataset has are skipped, and
+	// bagging ignores the feature count
+	if len(grid) != 2 || grid[0].Features != 2 || grid[1].Classifier != ClassifierBagging {
+		t.Errorf("got %v, want a forest with 2 features and bagging", grid)
+	}
+	if got := space.Sample(cols, 1, rand.New(rand.NewSource(1))); len(got) != 1 {
+		t.Errorf("got %d samples, want 1", len(got))
+	}
+}
+
+func TestFolds(t *testing.T) {
+	d := syntheticDataset(100, rand.New(rand.NewSource(1)))
+	_, spam := d.ClassCounts()
+	folds := Folds(d, 5, rand.New(rand.NewSource(1)))
+
+	seen := map[int]bool{}
+	for _, fold := range folds {
+		foldSpam := 0
+		for _, i := range fold {
+			if seen[i] {
+				t.Fatalf("row %d is in more than one fold", i)
+			}
+			seen[i] = true
+			foldSpam += d.Label(i)
+		}
+		if foldSpam < spam/5-1 || foldSpam > spam/5+1 {
+			t.Errorf("got %d spam rows in a fold, want about %d", foldSpam, spam/5)
+		}
+	}
+	if len(seen) != len(d.Rows) {
+		t.Errorf("got %d rows in folds, want %d", len(seen), len(d.Rows))
+	}
+}
+
+func TestCrossValidate(t *testing.T) {
+	d := syntheticDataset(200, rand.New(rand.NewSource(1)))
+	p := Params{Classifier: ClassifierBoosting, Trees: 20, MaxDepth: 2}
+	score, err := CrossValidate(d, p, 3, BalanceNone, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score < 0.8 {
+		t.Errorf("got F1 %.2f, want at least 0.8 on separable data", score)
+	}
+
+	small := syntheticDataset(4, rand.New(rand.NewSource(1)))
+	if _, err := CrossValidate(small, p, 3, BalanceNone, rand.New(rand.NewSource(1))); err == nil {
+		t.Error("expected an error for too few rows per fold")
+	}
+}
+
+func TestF1(t *testing.T) {
+	d := &Dataset{Cols: []string{"x", "is_spam"}}
+	for _, label := range []float64{1, 1, 0, 0} {
+		d.Append([]float64{0, label}, RowMeta{})
+	}
+	// one true positive, one false negative and one false positive
+	if got := F1(d, []float64{0.9, 0.1, 0.6, 0.2}, 0.5); got != 0.5 {
+		t.Errorf("got F1 %g, want 0.5", got)
+	}
+	if got := F1(d, []float64{0, 0, 0, 0}, 0.5); got != 0 {
+		t.Errorf("got F1 %g with no positives, want 0", got)
+	}
+}
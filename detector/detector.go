@@ -0,0 +1,328 @@
+// Package detector classifies GitHub issues, pull requests, discussions
+// and comments as spam, for programs that embed gh-spam.
+//
+//	d, err := detector.Load("data/cli-cli.gob", detector.GitHub{}, detector.Options{})
+//	res, err := d.ClassifyIssue(ctx, "cli", "cli", 4894)
+//	if res.Label == "spam" { ... }
+package detector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meiji163/gh-spam/classify"
+	"github.com/meiji163/gh-spam/spam"
+)
+
+// Recent items are indexed to find near-duplicates of classified items, as
+// they were for training
+const (
+	DupWindow = spam.DupWindow
+	DupLimit  = spam.DupLimit
+)
+
+// Options configure a Detector
+type Options struct {
+	// Kind is the kind of item the model was trained on: spam.KindIssue
+	// (the default), KindPR, KindDiscussion or KindComment
+	Kind string
+	// Config is the policy whose rules and thresholds are applied to the
+	// model's score. Nil is spam.DefaultConfig().
+	Config *spam.Config
+	// DupWindow and DupLimit bound the recent items indexed to find
+	// near-duplicates. 0 is the package's DupWindow and DupLimit.
+	DupWindow time.Duration
+	DupLimit  int
+}
+
+// Result is the classification of an item
+type Result struct {
+	Kind string
+	// Repo is the item's repository in OWNER/REPO format
+	Repo   string
+	Number int
+	URL    string
+	Author string
+	// Score is the spam probability after the policy's rules
+	Score float64
+	// ModelScore is the model's spam probability
+	ModelScore float64
+	// Label is "spam", "uncertain" or "not spam"
+	Label string
+	// Features are the model's inputs
+	Features map[string]float64
+	// Rules are the names of the policy rules that fired
+	Rules []string
+	// Reasons explain the label
+	Reasons []string
+}
+
+// Prediction returns the result as logged for the drift command
+func (r Result) Prediction() classify.Prediction {
+	return classify.Prediction{
+		Time:     time.Now().UTC(),
+		Kind:     r.Kind,
+		Number:   r.Number,
+		URL:      r.URL,
+		Score:    r.Score,
+		Verdict:  r.Label,
+		Features: r.Features,
+	}
+}
+
+// Detector classifies items with a model and a policy. Authors and each
+// repo's templates and recent items are looked up once and cached, so make
+// a new Detector to refresh them. A Detector is safe for concurrent use.
+type Detector struct {
+	model  classify.Model
+	source Source
+	opts   Options
+	config spam.Config
+	rules  *spam.RuleSet
+
+	// mu guards the caches. It isn't held while scoring or looking things
+	// up; the rules and duplicate indexes guard their own state.
+	mu       sync.Mutex
+	repos    map[string]*spam.Extractor
+	users    map[string]spam.User
+	activity map[string]spam.Activity
+}
+
+// New makes a Detector that scores items with a model and looks them up
+// with a source. The model may be nil if the Detector is only used to
+// extract Features.
+func New(model classify.Model, source Source, opts Options) (*Detector, error) {
+	if opts.Kind == "" {
+		opts.Kind = spam.KindIssue
+	}
+	if opts.DupWindow == 0 {
+		opts.DupWindow = DupWindow
+	}
+	if opts.DupLimit == 0 {
+		opts.DupLimit = DupLimit
+	}
+	config := spam.DefaultConfig()
+	if opts.Config != nil {
+		config = *opts.Config
+	}
+	rules, err := config.CompileRules()
+	if err != nil {
+		return nil, err
+	}
+	return &Detector{
+		model:    model,
+		source:   source,
+		opts:     opts,
+		config:   config,
+		rules:    rules,
+		repos:    map[string]*spam.Extractor{},
+		users:    map[string]spam.User{},
+		activity: map[string]spam.Activity{},
+	}, nil
+}
+
+// Load makes a Detector with a saved model, either an exported forest
+// (.json) or a trained model. A model trained for a min precision brings
+// its own spam threshold, which is used unless the policy sets one.
+func Load(modelPath string, source Source, opts Options) (*Detector, error) {
+	model, meta, err := classify.OpenModel(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil && meta.OperatingPoint != nil {
+		config := spam.DefaultConfig()
+		if opts.Config != nil {
+			config = *opts.Config
+		}
+		meta.OperatingPoint.Apply(&config)
+		opts.Config = &config
+	}
+	return New(model, source, opts)
+}
+
+// Config returns the policy the Detector applies
+func (d *Detector) Config() spam.Config {
+	return d.config
+}
+
+// ClassifyIssue looks up an item of the Detector's kind by number and
+// classifies it
+func (d *Detector) ClassifyIssue(ctx context.Context, owner, repo string, number int) (Result, error) {
+	issue, err := d.source.Item(ctx, d.opts.Kind, owner, repo, number)
+	if err != nil {
+		return Result{}, err
+	}
+	return d.Classify(ctx, issue)
+}
+
+// Classify looks up an item's author and classifies it
+func (d *Detector) Classify(ctx context.Context, issue spam.Issue) (Result, error) {
+	user, err := d.user(ctx, issue.Author)
+	if err != nil {
+		return Result{}, err
+	}
+	return d.classify(ctx, issue, user)
+}
+
+// Features looks up an item's author and returns the item's features, as
+// Classify would score them
+func (d *Detector) Features(ctx context.Context, issue spam.Issue) (spam.Features, error) {
+	user, err := d.user(ctx, issue.Author)
+	if err != nil {
+		return spam.Features{}, err
+	}
+	return d.features(ctx, issue, user)
+}
+
+// user returns an author's stats, looking them up the first time
+func (d *Detector) user(ctx context.Context, author spam.Actor) (spam.User, error) {
+	// bots and users are cached apart, in case their logins are the same
+	d.mu.Lock()
+	user, ok := d.users[author.SearchLogin()]
+	d.mu.Unlock()
+	if ok {
+		return user, nil
+	}
+	user, err := d.source.User(ctx, author)
+	if err != nil {
+		return user, fmt.Errorf("Error getting user stats for %s: %s", author.Login, err)
+	}
+	d.mu.Lock()
+	d.users[author.SearchLogin()] = user
+	d.mu.Unlock()
+	return user, nil
+}
+
+// ClassifyPayload classifies an item and its author as received, e.g. in a
+// webhook, without looking them up. The item's Repository must be set. The
+// author's activity and the repo's templates and recent items are still
+// looked up with the source.
+func (d *Detector) ClassifyPayload(issue spam.Issue, user spam.User) (Result, error) {
+	return d.classify(context.Background(), issue, user)
+}
+
+func (d *Detector) classify(ctx context.Context, issue spam.Issue, user spam.User) (Result, error) {
+	if d.model == nil {
+		return Result{}, fmt.Errorf("Detector has no model")
+	}
+	feat, err := d.features(ctx, issue, user)
+	if err != nil {
+		return Result{}, err
+	}
+	dataset := classify.NewDataset(classify.Columns(d.opts.Kind), []spam.Features{feat})
+	probs, err := d.model.Proba(dataset)
+	if err != nil {
+		return Result{}, err
+	}
+	rules, err := d.rules.Evaluate(issue)
+	if err != nil {
+		return Result{}, err
+	}
+	score := rules.Score(probs[0])
+
+	res := Result{
+		Kind:       d.opts.Kind,
+		Repo:       issue.Repository.NameWithOwner,
+		Number:     issue.Number,
+		URL:        issue.URL,
+		Author:     issue.Author.Login,
+		Score:      score,
+		ModelScore: probs[0],
+		Label:      d.config.Verdict(score),
+		Features:   map[string]float64{},
+		Rules:      rules.Fired,
+	}
+	for c, col := range dataset.Cols[:len(dataset.Cols)-1] {
+		res.Features[col] = dataset.Rows[0][c]
+	}
+	res.Reasons = d.reasons(res, rules)
+	return res, nil
+}
+
+// features extracts an item's features, looking up its author's activity
+// and the repo's templates and recent items if they aren't cached
+func (d *Detector) features(ctx context.Context, issue spam.Issue, user spam.User) (spam.Features, error) {
+	parts := strings.SplitN(issue.Repository.NameWithOwner, "/", 2)
+	if len(parts) != 2 {
+		return spam.Features{}, fmt.Errorf("%s #%d has no repository", d.opts.Kind, issue.Number)
+	}
+	owner, repo := parts[0], parts[1]
+	ext, err := d.extractor(ctx, owner, repo)
+	if err != nil {
+		return spam.Features{}, err
+	}
+
+	key := strings.ToLower(owner + "/" + repo + "/" + issue.Author.SearchLogin())
+	posted := spam.PostedAt(issue)
+	d.mu.Lock()
+	activity, ok := d.activity[key]
+	d.mu.Unlock()
+	if !ok || !activity.Covers(posted) {
+		if activity, err = d.source.Activity(ctx, owner, repo, issue.Author, posted); err != nil {
+			return spam.Features{}, fmt.Errorf("Error getting activity for %s: %s", issue.Author.Login, err)
+		}
+		d.mu.Lock()
+		d.activity[key] = activity
+		d.mu.Unlock()
+	}
+	return ext.Features(issue, user, activity), nil
+}
+
+// reasons explains how the model's score, the rules and the thresholds
+// led to a result's label
+func (d *Detector) reasons(res Result, rules spam.RuleResult) []string {
+	if rules.Verdict != "" {
+		return []string{fmt.Sprintf("rule %s: %s", rules.Fired[len(rules.Fired)-1], rules.Verdict)}
+	}
+	reasons := []string{fmt.Sprintf("model score %.2f", res.ModelScore)}
+	if len(rules.Fired) > 0 {
+		reasons = append(reasons, fmt.Sprintf("rules %s adjusted the score by %+.2f", strings.Join(rules.Fired, ", "), rules.Adjust))
+	}
+	thresholds := d.config.Thresholds
+	switch res.Label {
+	case "spam":
+		reasons = append(reasons, fmt.Sprintf("score %.2f is at least the spam threshold %.2f", res.Score, thresholds.Spam))
+	case "uncertain":
+		reasons = append(reasons, fmt.Sprintf("score %.2f is between the uncertain threshold %.2f and the spam threshold %.2f", res.Score, thresholds.Uncertain, thresholds.Spam))
+	default:
+		reasons = append(reasons, fmt.Sprintf("score %.2f is below the uncertain threshold %.2f", res.Score, thresholds.Uncertain))
+	}
+	return reasons
+}
+
+// extractor returns the feature extractor for a repo, looking up its
+// templates and indexing its recent items the first time
+func (d *Detector) extractor(ctx context.Context, owner, repo string) (*spam.Extractor, error) {
+	key := strings.ToLower(owner + "/" + repo)
+	d.mu.Lock()
+	ext, ok := d.repos[key]
+	d.mu.Unlock()
+	if ok {
+		return ext, nil
+	}
+
+	templates, err := d.source.Templates(ctx, d.opts.Kind, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := d.source.RecentItems(ctx, d.opts.Kind, owner, repo, time.Now().Add(-d.opts.DupWindow), d.opts.DupLimit)
+	if err != nil {
+		return nil, err
+	}
+	dups := spam.NewDupIndex(spam.DefaultDupThreshold)
+	for _, issue := range recent {
+		dups.AddItem(issue)
+	}
+	ext = spam.NewExtractor(owner, repo, templates, dups)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cached, ok := d.repos[key]; ok {
+		return cached, nil
+	}
+	d.repos[key] = ext
+	return ext, nil
+}
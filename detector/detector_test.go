@@ -0,0 +1,163 @@
+package detector
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meiji163/gh-spam/classify"
+	"github.com/meiji163/gh-spam/spam"
+)
+
+// fakeSource serves one repo's items from memory and counts user lookups
+type fakeSource struct {
+	items map[int]spam.Issue
+	users map[string]spam.User
+	calls int
+}
+
+func (s *fakeSource) Item(ctx context.Context, kind, owner, repo string, number int) (spam.Issue, error) {
+	return s.items[number], nil
+}
+
+func (s *fakeSource) User(ctx context.Context, author spam.Actor) (spam.User, error) {
+	s.calls++
+	return s.users[author.Login], nil
+}
+
+func (s *fakeSource) Activity(ctx context.Context, owner, repo string, author spam.Actor, posted time.Time) (spam.Activity, error) {
+	return spam.Activity{}, nil
+}
+
+func (s *fakeSource) Templates(ctx context.Context, kind, owner, repo string) ([]spam.Template, error) {
+	return nil, nil
+}
+
+func (s *fakeSource) RecentItems(ctx context.Context, kind, owner, repo string, since time.Time, limit int) ([]spam.Issue, error) {
+	return nil, nil
+}
+
+// followerModel scores authors with few followers as spam
+type followerModel struct{}
+
+func (followerModel) Proba(d *classify.Dataset) ([]float64, error) {
+	col := -1
+	for i, name := range d.Cols {
+		if name == "followers" {
+			col = i
+		}
+	}
+	probs := make([]float64, len(d.Rows))
+	for i, row := range d.Rows {
+		probs[i] = 0.9
+		if row[col] >= 10 {
+			probs[i] = 0.1
+		}
+	}
+	return probs, nil
+}
+
+func (followerModel) Save(path string) error {
+	return nil
+}
+
+func newIssue(number int, login, title string) spam.Issue {
+	issue := spam.Issue{Kind: spam.KindIssue, Number: number, Title: title, CreatedAt: "2022-01-10T00:00:00Z"}
+	issue.Author.Login = login
+	issue.Repository.NameWithOwner = "cli/cli"
+	return issue
+}
+
+func TestDetector(t *testing.T) {
+	source := &fakeSource{
+		items: map[int]spam.Issue{
+			1: newIssue(1, "spammer", "Buy now"),
+			2: newIssue(2, "spammer", "Buy again"),
+			3: newIssue(3, "monalisa", "Crash on startup"),
+		},
+		users: map[string]spam.User{
+			"spammer":  {Name: "spammer", Followers: 0},
+			"monalisa": {Name: "monalisa", Followers: 50},
+		},
+	}
+	config := spam.DefaultConfig()
+	config.Keywords = []string{"free followers"}
+	d, err := New(followerModel{}, source, Options{Config: &config})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for number, want := range map[int]string{1: "spam", 2: "spam", 3: "not spam"} {
+		res, err := d.ClassifyIssue(ctx, "cli", "cli", number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Label != want || res.Repo != "cli/cli" || res.Number != number {
+			t.Errorf("#%d: got %s in %s #%d, want %s", number, res.Label, res.Repo, res.Number, want)
+		}
+		if _, ok := res.Features["followers"]; !ok {
+			t.Errorf("#%d: got features %v, want followers", number, res.Features)
+		}
+		if last := res.Reasons[len(res.Reasons)-1]; !strings.Contains(last, "threshold") {
+			t.Errorf("#%d: got reasons %v, want the threshold", number, res.Reasons)
+		}
+	}
+	if source.calls != 2 {
+		t.Errorf("got %d user lookups, want one per author", source.calls)
+	}
+
+	res, err := d.ClassifyPayload(newIssue(4, "monalisa", "Get free followers"), source.users["monalisa"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Label != "spam" || res.ModelScore != 0.1 || len(res.Rules) != 1 || res.Rules[0] != "keyword" {
+		t.Errorf("got %+v, want spam by the keyword rule", res)
+	}
+	if source.calls != 2 {
+		t.Error("expected the payload's user not to be looked up")
+	}
+
+	if _, err := d.ClassifyPayload(spam.Issue{Number: 5}, spam.User{}); err == nil {
+		t.Error("expected an error for a payload without a repository")
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	source := &fakeSource{users: map[string]spam.User{"monalisa": {Name: "monalisa", Followers: 50}}}
+	d, err := New(nil, source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feat, err := d.Features(context.Background(), newIssue(1, "monalisa", "Crash on startup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feat.Followers != 50 {
+		t.Errorf("got %d followers, want 50", feat.Followers)
+	}
+	if _, err := d.Classify(context.Background(), newIssue(1, "monalisa", "Crash on startup")); err == nil {
+		t.Error("expected an error classifying without a model")
+	}
+}
+
+func TestDetectorConcurrent(t *testing.T) {
+	d, err := New(followerModel{}, &fakeSource{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := d.ClassifyPayload(newIssue(i+1, "spammer", "Buy now at our store"), spam.User{})
+			if err != nil || res.Label != "spam" {
+				t.Errorf("#%d: got %s, %v", i+1, res.Label, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
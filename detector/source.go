@@ -0,0 +1,78 @@
+package detector
+
+import (
+	"context"
+	"time"
+
+	"github.com/meiji163/gh-spam/spam"
+)
+
+// Source looks up the items a Detector classifies, their authors, and the
+// repo's templates and recent items they're compared to
+type Source interface {
+	// Item gets an item of a kind by number
+	Item(ctx context.Context, kind, owner, repo string, number int) (spam.Issue, error)
+	// User gets an item's author's profile and contribution stats
+	User(ctx context.Context, author spam.Actor) (spam.User, error)
+	// Activity gets an author's issues and pull requests in a repo, and
+	// their issues outside of it around when an item was posted
+	Activity(ctx context.Context, owner, repo string, author spam.Actor, posted time.Time) (spam.Activity, error)
+	// Templates gets a repo's templates for a kind of item
+	Templates(ctx context.Context, kind, owner, repo string) ([]spam.Template, error)
+	// RecentItems gets items of a kind created since a time, newest first
+	RecentItems(ctx context.Context, kind, owner, repo string, since time.Time, limit int) ([]spam.Issue, error)
+}
+
+// GitHub looks everything up with the GitHub API, as the gh user. The
+// context is checked before each request, but doesn't cancel requests in
+// flight.
+type GitHub struct{}
+
+func (GitHub) Item(ctx context.Context, kind, owner, repo string, number int) (spam.Issue, error) {
+	if err := ctx.Err(); err != nil {
+		return spam.Issue{}, err
+	}
+	return spam.GetItemByNumber(kind, owner, repo, number)
+}
+
+func (GitHub) User(ctx context.Context, author spam.Actor) (spam.User, error) {
+	if err := ctx.Err(); err != nil {
+		return spam.User{}, err
+	}
+	return spam.GetAuthorStats(author)
+}
+
+func (GitHub) Activity(ctx context.Context, owner, repo string, author spam.Actor, posted time.Time) (spam.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return spam.Activity{}, err
+	}
+	return spam.GetAuthorActivity(owner, repo, author.SearchLogin(), posted)
+}
+
+func (GitHub) Templates(ctx context.Context, kind, owner, repo string) ([]spam.Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return spam.GetTemplatesFor(kind, owner, repo)
+}
+
+func (GitHub) RecentItems(ctx context.Context, kind, owner, repo string, since time.Time, limit int) ([]spam.Issue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return spam.GetRecentItems(kind, owner, repo, since, limit)
+}
+
+// Local reads templates from a checkout of the repo at Root, and looks up
+// everything else with the GitHub API
+type Local struct {
+	GitHub
+	Root string
+}
+
+func (l Local) Templates(ctx context.Context, kind, owner, repo string) ([]spam.Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return spam.LocalTemplates(kind, l.Root)
+}
@@ -8,6 +8,7 @@ require (
 	github.com/ktr0731/go-fuzzyfinder v0.5.1
 	github.com/sjwhitworth/golearn v0.0.0-20211014193759-a8b69c276cd8
 	github.com/spf13/cobra v0.0.2-0.20171109065643-2da4a54c5cee
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
 
 require (
@@ -32,5 +33,4 @@ require (
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a // indirect
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
 	gonum.org/v1/gonum v0.8.1 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
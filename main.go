@@ -1,23 +1,44 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/cli/go-gh"
 	"github.com/meiji163/gh-spam/classify"
+	"github.com/meiji163/gh-spam/detector"
 	"github.com/meiji163/gh-spam/spam"
-	"github.com/sjwhitworth/golearn/base"
-	"github.com/sjwhitworth/golearn/ensemble"
 	"github.com/sjwhitworth/golearn/evaluation"
 	"github.com/spf13/cobra"
 )
 
-const numTrees = 61
+// chooseThreshold prints at most prCurveRows points of the precision/recall curve
+const prCurveRows = 15
+
+// downloadLimit is the default number of issues in a dataset
+const downloadLimit = 600
+
+// predictions made within driftWindow are compared to the training set
+const driftWindow = 30 * 24 * time.Hour
+
+// the first feedback sync reads events within feedbackWindow
+const feedbackWindow = 90 * 24 * time.Hour
+
+// recent issues are indexed to find near-duplicates of classified issues
+const (
+	dupWindow = detector.DupWindow
+	dupLimit  = detector.DupLimit
+)
 
 func main() {
 	cmd := rootCmd()
@@ -27,14 +48,41 @@ func main() {
 }
 
 type SpamOpts struct {
-	Numbers   []int
-	RepoArg   string
-	Repo      string
-	Owner     string
-	DataPath  string
-	ModelPath string
-	Limit     int
-	Verbose   bool
+	Numbers      []int
+	Comments     []string
+	RepoArg      string
+	Repo         string
+	Owner        string
+	Kind         string
+	DataPath     string
+	ModelPath    string
+	LogPath      string
+	ConfigPath   string
+	Config       spam.Config
+	Limit        int
+	SpamRatio    float64
+	Balance      string
+	Seed         int64
+	Search       string
+	Iterations   int
+	Folds        int
+	Space        classify.SearchSpace
+	Retrain      bool
+	Output       string
+	TestSize     float64
+	Source       string
+	Label        string
+	Params       classify.Params
+	Calibrate    string
+	MinPrecision float64
+	SpecPath     string
+	Format       string
+	Local        string
+	Offline      bool
+	Since        time.Duration
+	Threshold    float64
+	Apply        bool
+	Verbose      bool
 }
 
 func rootCmd() *cobra.Command {
@@ -44,30 +92,17 @@ func rootCmd() *cobra.Command {
 		Short: "Classify GitHub issues as spam.",
 		Args:  cobra.ExactArgs(1),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			if opts.RepoArg == "" {
-				repo, err := gh.CurrentRepository()
-				if err != nil {
-					return fmt.Errorf("No repository argument")
-				}
-				opts.Repo = repo.Name()
-				opts.Owner = repo.Owner()
-			} else {
-				ownerRepo := strings.Split(opts.RepoArg, "/")
-				if len(ownerRepo) != 2 {
-					return fmt.Errorf("Invalid repository argument")
-				}
-				opts.Owner = ownerRepo[0]
-				opts.Repo = ownerRepo[1]
-			}
-
-			opts.DataPath = filepath.Join("data", fmt.Sprintf("%s-%s.csv", opts.Owner, opts.Repo))
-			opts.ModelPath = filepath.Join("data", fmt.Sprintf("%s-%s.gob", opts.Owner, opts.Repo))
-			return nil
+			return loadRepo(opts)
 		},
 	}
 
 	cmd.PersistentFlags().StringVarP(&opts.RepoArg, "repo", "R", "", "specify the repository in OWNER/REPO format")
+	cmd.PersistentFlags().StringVarP(&opts.ConfigPath, "config", "c", "", fmt.Sprintf("read policy from a local file instead of the repo's %s", spam.ConfigPath))
+	cmd.PersistentFlags().StringVarP(&opts.Kind, "kind", "k", spam.KindIssue, "kind of item: issue, pr, discussion or comment")
 	cmd.PersistentFlags().BoolVarP(&opts.Verbose, "verbose", "v", false, "verbose mode")
+	cmd.PersistentFlags().StringVar(&opts.Local, "local", "", "read templates, policy, CODEOWNERS and MAINTAINERS from a checkout of the repo")
+	cmd.PersistentFlags().Lookup("local").NoOptDefVal = "."
+	cmd.PersistentFlags().BoolVar(&opts.Offline, "offline", false, "only use cached GitHub API responses")
 
 	downloadCmd := &cobra.Command{
 		Use:   "download",
@@ -77,12 +112,22 @@ func rootCmd() *cobra.Command {
 			return runDownload(opts)
 		},
 	}
-	downloadCmd.Flags().IntVarP(&opts.Limit, "limit", "L", 600, "max number of issues to download")
+	downloadCmd.Flags().IntVarP(&opts.Limit, "limit", "L", downloadLimit, "max number of issues to download")
+	downloadCmd.Flags().Float64Var(&opts.SpamRatio, "spam-ratio", 0.5, "fraction of the limit reserved for spam issues")
+	downloadCmd.Flags().StringVar(&opts.Balance, "balance", classify.BalanceNone, "balance classes for training: none, weight, undersample or smote")
+	downloadCmd.Flags().Int64Var(&opts.Seed, "seed", 42, "random seed for sampling and training")
+	downloadCmd.Flags().StringVar(&opts.Calibrate, "calibrate", classify.CalibrationNone, "calibrate scores on a held-out split: none, platt or isotonic")
+	downloadCmd.Flags().Float64Var(&opts.MinPrecision, "min-precision", 0, "choose the spam threshold with the best recall at this precision on a held-out split")
 
 	classifyCmd := &cobra.Command{
 		Use:   "classify <number>",
-		Short: "Classify issues as spam. Accepts one or more issue numbers",
-		Args:  cobra.MinimumNArgs(1),
+		Short: "Classify issues as spam. Accepts one or more issue numbers, or --comment URLs",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(opts.Comments) > 0 {
+				return cobra.ExactArgs(0)(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			for _, arg := range args {
 				num, err := strconv.Atoi(arg)
@@ -95,113 +140,1583 @@ func rootCmd() *cobra.Command {
 			return runClassify(opts)
 		},
 	}
+	classifyCmd.Flags().BoolVar(&opts.Apply, "apply", false, "take the policy's actions on spam and uncertain issues")
+	classifyCmd.Flags().StringArrayVar(&opts.Comments, "comment", nil, "classify an issue or pull request comment by URL")
+
+	scanCmd := &cobra.Command{
+		Use:   "scan",
+		Short: "Classify recently opened issues",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScan(opts)
+		},
+	}
+	scanCmd.Flags().DurationVar(&opts.Since, "since", 24*time.Hour, "scan issues opened within this duration")
+	scanCmd.Flags().IntVarP(&opts.Limit, "limit", "L", 100, "max number of issues to scan")
+	scanCmd.Flags().BoolVar(&opts.Apply, "apply", false, "take the policy's actions on spam and uncertain issues")
+
+	dupesCmd := &cobra.Command{
+		Use:   "dupes",
+		Short: "List clusters of near-duplicate issues",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDupes(opts)
+		},
+	}
+	dupesCmd.Flags().DurationVar(&opts.Since, "since", dupWindow, "look at issues opened within this duration")
+	dupesCmd.Flags().IntVarP(&opts.Limit, "limit", "L", dupLimit, "max number of issues to look at")
+	dupesCmd.Flags().Float64Var(&opts.Threshold, "threshold", spam.DefaultDupThreshold, "min similarity of near-duplicates")
+
+	tuneCmd := &cobra.Command{
+		Use:   "tune",
+		Short: "Search for the best classifier hyperparameters on the downloaded dataset",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTune(opts)
+		},
+	}
+	tuneCmd.Flags().StringVar(&opts.Search, "search", "grid", "search strategy: grid or random")
+	tuneCmd.Flags().IntVar(&opts.Iterations, "iterations", 10, "number of random combinations to try")
+	tuneCmd.Flags().IntVar(&opts.Folds, "folds", 5, "number of cross-validation folds")
+	tuneCmd.Flags().StringSliceVar(&opts.Space.Classifiers, "classifiers", classify.DefaultSpace.Classifiers, "classifiers to try: forest, bagging or boosting")
+	tuneCmd.Flags().IntSliceVar(&opts.Space.Trees, "trees", classify.DefaultSpace.Trees, "numbers of trees to try, the most for boosting")
+	tuneCmd.Flags().IntSliceVar(&opts.Space.Features, "features-per-tree", classify.DefaultSpace.Features, "numbers of features each forest tree samples to try")
+	tuneCmd.Flags().IntSliceVar(&opts.Space.MaxDepth, "depth", classify.DefaultSpace.MaxDepth, "max tree depths to try, 0 is unlimited")
+	tuneCmd.Flags().StringVar(&opts.Balance, "balance", classify.BalanceNone, "balance classes for training: none, weight, undersample or smote")
+	tuneCmd.Flags().Int64Var(&opts.Seed, "seed", 42, "random seed for folds, search and training (forests still vary)")
+	tuneCmd.Flags().StringVar(&opts.Calibrate, "calibrate", classify.CalibrationNone, "calibrate scores on a held-out split: none, platt or isotonic")
+	tuneCmd.Flags().Float64Var(&opts.MinPrecision, "min-precision", 0, "choose the spam threshold with the best recall at this precision on a held-out split")
+
+	driftCmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Compare recent predictions to the training set, and recommend retraining",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDrift(opts)
+		},
+	}
+	driftCmd.Flags().DurationVar(&opts.Since, "since", driftWindow, "look at predictions made within this duration")
+	driftCmd.Flags().BoolVar(&opts.Retrain, "retrain", false, "download a new dataset and retrain if a drift bound is crossed")
 
-	cmd.AddCommand(downloadCmd, classifyCmd)
+	syncCmd := &cobra.Command{
+		Use:   "sync-feedback",
+		Short: "Correct dataset labels from maintainers' spam labels and reopened issues",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSyncFeedback(opts)
+		},
+	}
+	syncCmd.Flags().DurationVar(&opts.Since, "since", 0, fmt.Sprintf("read events within this duration instead of since the last sync (default %s on the first sync)", feedbackWindow))
+	syncCmd.Flags().IntVarP(&opts.Limit, "limit", "L", 1000, "max number of updated issues to read")
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the trained model as JSON for the classify/forest package",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(opts)
+		},
+	}
+	exportCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output file (default next to the model, e.g. data/cli-cli-forest.json)")
+
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import a decision tree or forest trained with scikit-learn",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(opts, args[0])
+		},
+	}
+	importCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output file (default next to the model, e.g. data/cli-cli-imported.json)")
+
+	reportCmd := &cobra.Command{
+		Use:   "report [file]",
+		Short: "Compare classifiers and feature sets on a held-out split of the dataset",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReport(cmd, opts, datasetArg(opts, args))
+		},
+	}
+	reportCmd.Flags().StringVar(&opts.SpecPath, "spec", "", "YAML file of models and feature sets to compare (default forest, bagging and boosting on all features)")
+	reportCmd.Flags().StringVar(&opts.Format, "format", "", "report format: markdown or html (default from the output file, else markdown)")
+	reportCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output file (default stdout)")
+	reportCmd.Flags().Int64Var(&opts.Seed, "seed", classify.DefaultReportSpec.Seed, "random seed for the split and training")
+	reportCmd.Flags().Float64Var(&opts.TestSize, "test-size", classify.DefaultReportSpec.TestSize, "fraction of each class to test on")
+	reportCmd.Flags().StringVar(&opts.Balance, "balance", classify.BalanceNone, "balance classes for training: none, weight, undersample or smote")
+
+	cmd.AddCommand(downloadCmd, classifyCmd, scanCmd, dupesCmd, tuneCmd, driftCmd, syncCmd, exportCmd, importCmd, reportCmd, datasetCmd(opts))
 	return cmd
 }
 
-func runClassify(opts *SpamOpts) error {
-	if _, err := os.Stat(opts.ModelPath); errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("model for %s/%s not found", opts.Owner, opts.Repo)
+// datasetCmd groups the commands for inspecting and editing datasets.
+// They work on the repo's dataset unless given files, in which case the
+// repo and its policy aren't loaded.
+func datasetCmd(opts *SpamOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dataset",
+		Short: "Inspect, combine and convert datasets",
+	}
+
+	infoCmd := &cobra.Command{
+		Use:   "info [<file>]",
+		Short: "Show the size, class balance and column statistics of a dataset",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetInfo(opts, datasetArg(opts, args))
+		},
+	}
+
+	mergeCmd := &cobra.Command{
+		Use:   "merge <file>...",
+		Short: "Combine datasets with the same columns",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetMerge(opts, args)
+		},
+	}
+	// merging into the repo's dataset by default would overwrite it
+	mergeCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output file")
+	_ = mergeCmd.MarkFlagRequired("output")
+
+	dedupeCmd := &cobra.Command{
+		Use:   "dedupe [<file>]",
+		Short: "Keep one row per issue number, preferring maintainer-confirmed labels",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetDedupe(opts, datasetArg(opts, args))
+		},
+	}
+	dedupeCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output file (default overwrite the input)")
+
+	splitCmd := &cobra.Command{
+		Use:   "split [<file>]",
+		Short: "Split a dataset into stratified train and test sets",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetSplit(opts, datasetArg(opts, args))
+		},
+	}
+	splitCmd.Flags().Float64Var(&opts.TestSize, "test-size", 0.2, "fraction of each class in the test set")
+	splitCmd.Flags().Int64Var(&opts.Seed, "seed", 42, "random seed for the split")
+
+	exportCmd := &cobra.Command{
+		Use:   "export [<file>]",
+		Short: "Convert a dataset to JSON lines for pandas and other tools",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetExport(opts, datasetArg(opts, args))
+		},
+	}
+	exportCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output file (default the input with a .jsonl extension)")
+
+	rowsCmd := &cobra.Command{
+		Use:   "rows [<file>]",
+		Short: "List the rows of a dataset with where they came from, to review labels",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetRows(opts, datasetArg(opts, args))
+		},
+	}
+	rowsCmd.Flags().StringVar(&opts.Source, "source", "", "only list rows with this label source: heuristic, manual or feedback")
+	rowsCmd.Flags().StringVar(&opts.Label, "label", "", "only list rows with this label: spam or not-spam")
+
+	labelCmd := &cobra.Command{
+		Use:   "label {<number>|<owner>/<repo>#<number>} {spam|not-spam} [<file>]",
+		Short: "Correct the label of a row by hand",
+		Args:  cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetLabel(opts, args[0], args[1], datasetArg(opts, args[2:]))
+		},
+	}
+
+	makeCmd := &cobra.Command{
+		Use:   "make",
+		Short: "Download a labeled dataset without training a model",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetMake(opts)
+		},
+	}
+	makeCmd.Flags().IntVarP(&opts.Limit, "limit", "L", downloadLimit, "max number of issues to download")
+	makeCmd.Flags().Float64Var(&opts.SpamRatio, "spam-ratio", 0.5, "fraction of the limit reserved for spam issues")
+	makeCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output file (default the repo's dataset)")
+
+	trainCmd := &cobra.Command{
+		Use:   "train [<file>]",
+		Short: "Train and evaluate a model on any dataset, without replacing the repo's model",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDatasetTrain(opts, datasetArg(opts, args))
+		},
+	}
+	trainCmd.Flags().StringVar(&opts.Params.Classifier, "classifier", classify.DefaultParams.Classifier, "classifier: forest, bagging or boosting")
+	trainCmd.Flags().IntVar(&opts.Params.Trees, "trees", classify.DefaultParams.Trees, "number of trees")
+	trainCmd.Flags().IntVar(&opts.Params.Features, "features-per-tree", classify.DefaultParams.Features, "number of features each forest tree samples")
+	trainCmd.Flags().IntVar(&opts.Params.MaxDepth, "depth", classify.DefaultParams.MaxDepth, "max tree depth, 0 is unlimited (3 for boosting)")
+	trainCmd.Flags().Float64Var(&opts.Params.LearningRate, "learning-rate", 0, "learning rate of boosting (default 0.1)")
+	trainCmd.Flags().StringVar(&opts.Balance, "balance", classify.BalanceNone, "balance classes for training: none, weight, undersample or smote")
+	trainCmd.Flags().Float64Var(&opts.TestSize, "test-size", 0.2, "fraction of each class held out for evaluation")
+	trainCmd.Flags().Int64Var(&opts.Seed, "seed", 42, "random seed for the split, sampling and training")
+	trainCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "save the model to this .gob file")
+	trainCmd.Flags().StringVar(&opts.Calibrate, "calibrate", classify.CalibrationNone, "calibrate scores on a held-out split: none, platt or isotonic")
+	trainCmd.Flags().Float64Var(&opts.MinPrecision, "min-precision", 0, "choose the spam threshold with the best recall at this precision on a held-out split")
+
+	for _, c := range []*cobra.Command{infoCmd, mergeCmd, dedupeCmd, splitCmd, exportCmd, rowsCmd, trainCmd} {
+		c.PersistentPreRunE = filesOnly(opts, 0)
+	}
+	labelCmd.PersistentPreRunE = filesOnly(opts, 2)
+
+	cmd.AddCommand(infoCmd, mergeCmd, dedupeCmd, splitCmd, exportCmd, rowsCmd, labelCmd, makeCmd, trainCmd)
+	return cmd
+}
+
+// filesOnly skips loading the repo and its policy for a dataset command
+// given files as args[i:], which don't need them
+func filesOnly(opts *SpamOpts, i int) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) > i {
+			return checkKind(opts)
+		}
+		return loadRepo(opts)
+	}
+}
+
+// datasetArg returns the dataset file argument, or the repo's dataset
+func datasetArg(opts *SpamOpts, args []string) string {
+	if len(args) > 0 {
+		return args[0]
 	}
+	return opts.DataPath
+}
 
-	tree := ensemble.NewRandomForest(numTrees, 9)
-	if err := tree.Load(opts.ModelPath); err != nil {
+// loadConfig reads the policy from --config or the repo, and applies its settings
+// loadRepo resolves the repo from -R or the current checkout, sets the
+// paths of its data, and loads its policy
+func loadRepo(opts *SpamOpts) error {
+	if opts.RepoArg == "" {
+		repo, err := gh.CurrentRepository()
+		if err != nil {
+			return fmt.Errorf("No repository argument")
+		}
+		opts.Repo = repo.Name()
+		opts.Owner = repo.Owner()
+	} else {
+		ownerRepo := strings.Split(opts.RepoArg, "/")
+		if len(ownerRepo) != 2 {
+			return fmt.Errorf("Invalid repository argument")
+		}
+		opts.Owner = ownerRepo[0]
+		opts.Repo = ownerRepo[1]
+	}
+	if err := checkKind(opts); err != nil {
 		return err
 	}
 
-	templates, err := spam.GetTemplates(opts.Owner, opts.Repo)
+	opts.DataPath = kindPath(opts.Kind, filepath.Join("data", fmt.Sprintf("%s-%s.csv", opts.Owner, opts.Repo)))
+	opts.ModelPath = kindPath(opts.Kind, filepath.Join("data", fmt.Sprintf("%s-%s.gob", opts.Owner, opts.Repo)))
+	spam.CacheDir = filepath.Join("data", "cache")
+	spam.Offline = opts.Offline
+	opts.LogPath = kindPath(opts.Kind, filepath.Join("data", fmt.Sprintf("%s-%s-predictions.jsonl", opts.Owner, opts.Repo)))
+	return loadConfig(opts)
+}
+
+// checkKind sets and validates the kind of item
+func checkKind(opts *SpamOpts) error {
+	if len(opts.Comments) > 0 {
+		opts.Kind = spam.KindComment
+	}
+	if !validKinds[opts.Kind] {
+		return fmt.Errorf("Invalid kind %q, expected issue, pr, discussion or comment", opts.Kind)
+	}
+	return nil
+}
+
+func loadConfig(opts *SpamOpts) error {
+	var err error
+	switch {
+	case opts.ConfigPath != "":
+		opts.Config, err = spam.LoadConfig(opts.ConfigPath)
+	case opts.Local != "":
+		opts.Config, err = spam.LocalConfig(opts.Local)
+	default:
+		opts.Config, err = spam.GetRepoConfig(opts.Owner, opts.Repo)
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("Error loading config: %s", err)
+	}
+
+	// code owners and maintainers don't post spam
+	if opts.Local != "" {
+		users, teams, err := spam.LocalMaintainers(opts.Local)
+		if err != nil {
+			return err
+		}
+		opts.Config.Allow.Users = append(opts.Config.Allow.Users, users...)
+		opts.Config.Allow.Teams = append(opts.Config.Allow.Teams, teams...)
+	}
+
+	if opts.Config.Model != "" {
+		opts.ModelPath = kindPath(opts.Kind, opts.Config.Model)
+	}
+
+	// a model trained for a min precision brings its own spam threshold,
+	// unless the policy sets one
+	if filepath.Ext(opts.ModelPath) != ".json" {
+		if meta, err := classify.ReadMeta(opts.ModelPath); err == nil && meta.OperatingPoint != nil {
+			op := meta.OperatingPoint
+			if !op.Apply(&opts.Config) {
+				fmt.Fprintf(os.Stderr, "warning: using the policy's spam threshold %.3f rather than the model's %.3f for precision %.2f\n", opts.Config.Thresholds.Spam, op.Threshold, op.MinPrecision)
+			} else if opts.Verbose {
+				fmt.Fprintf(os.Stderr, "spam threshold %.3f for precision %.2f\n", opts.Config.Thresholds.Spam, op.MinPrecision)
+			}
+		}
 	}
+	return nil
+}
+
+var validKinds = map[string]bool{
+	spam.KindIssue:      true,
+	spam.KindPR:         true,
+	spam.KindDiscussion: true,
+	spam.KindComment:    true,
+}
 
+// kindPath adds the kind to a dataset or model path, e.g. data/cli-cli-pr.gob.
+// Issues keep the plain path.
+func kindPath(kind, path string) string {
+	if kind == spam.KindIssue {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + kind + ext
+}
+
+func runClassify(opts *SpamOpts) error {
 	issues := []spam.Issue{}
-	feats := []spam.Features{}
+	for _, commentURL := range opts.Comments {
+		owner, repo, _, err := spam.ParseCommentURL(commentURL)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(owner, opts.Owner) || !strings.EqualFold(repo, opts.Repo) {
+			return fmt.Errorf("Comment %s is not in %s/%s", commentURL, opts.Owner, opts.Repo)
+		}
+		comment, err := spam.GetCommentByURL(commentURL)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, comment)
+	}
+
 	for _, num := range opts.Numbers {
-		issue, err := spam.GetIssueByNumber(opts.Owner, opts.Repo, num)
+		issue, err := spam.GetItemByNumber(opts.Kind, opts.Owner, opts.Repo, num)
 		if err != nil {
 			return err
 		}
 		issues = append(issues, issue)
+	}
+	return classifyIssues(opts, issues)
+}
+
+func runScan(opts *SpamOpts) error {
+	issues, err := spam.GetOpenItems(opts.Kind, opts.Owner, opts.Repo, time.Now().Add(-opts.Since), opts.Limit)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		fmt.Printf("no new %ss\n", opts.Kind)
+		return nil
+	}
+	return classifyIssues(opts, issues)
+}
+
+// classifyIssues prints the policy's verdict for each issue,
+// and takes the policy's actions if --apply is set
+func classifyIssues(opts *SpamOpts, issues []spam.Issue) error {
+	if _, err := os.Stat(opts.ModelPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s model for %s/%s not found", opts.Kind, opts.Owner, opts.Repo)
+	}
+
+	det, err := detector.Load(opts.ModelPath, newSource(opts), detector.Options{Kind: opts.Kind, Config: &opts.Config})
+	if err != nil {
+		return err
+	}
+	if opts.Apply {
+		for _, action := range opts.Config.SkippedActions(opts.Kind) {
+			fmt.Fprintf(os.Stderr, "warning: the %s action can't be taken on %ss, so it is skipped\n", action, opts.Kind)
+		}
+	}
+	// calibrated scores are probabilities, so always show them
+	showScore := opts.Verbose
+	if meta, err := classify.ReadMeta(opts.ModelPath); err == nil && meta.Calibration != nil {
+		showScore = true
+	}
+
+	preds := []classify.Prediction{}
+	defer func() {
+		if err := logPredictions(opts, preds); err != nil {
+			fmt.Fprintf(os.Stderr, "Error logging predictions: %s\n", err)
+		}
+	}()
 
-		username := issue.Author.Login
-		author, err := spam.GetUserStats(username)
+	ctx := context.Background()
+	for _, issue := range issues {
+		res, err := det.Classify(ctx, issue)
 		if err != nil {
-			return fmt.Errorf("Error getting user stats for %s: %s", username, err)
+			return err
+		}
+
+		name := fmt.Sprintf("#%d", issue.Number)
+		if issue.Kind == spam.KindComment {
+			name = issue.URL
 		}
+		out := fmt.Sprintf("%s: %s", name, res.Label)
+		if showScore {
+			out += fmt.Sprintf(" %.2f", res.Score)
+		}
+		if len(res.Rules) > 0 {
+			out += fmt.Sprintf(" (rules: %s)", strings.Join(res.Rules, ", "))
+		}
+		fmt.Println(out)
+
+		pred := res.Prediction()
+		pred.Applied = opts.Apply && res.Label != "not spam"
+		preds = append(preds, pred)
+
+		if opts.Apply {
+			data := spam.ActionData{
+				Owner:   opts.Owner,
+				Repo:    opts.Repo,
+				Kind:    opts.Kind,
+				ID:      issue.ID,
+				Number:  issue.Number,
+				Author:  issue.Author.Login,
+				Score:   res.Score,
+				Verdict: res.Label,
+			}
+			if err := opts.Config.Apply(data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newSource looks up items with GitHub, and templates in the --local checkout
+func newSource(opts *SpamOpts) detector.Source {
+	if opts.Local != "" {
+		return detector.Local{Root: opts.Local}
+	}
+	return detector.GitHub{}
+}
+
+// getTemplates reads templates from the --local checkout, or the repo
+func getTemplates(opts *SpamOpts) ([]spam.Template, error) {
+	if opts.Local != "" {
+		return spam.LocalTemplates(opts.Kind, opts.Local)
+	}
+	return spam.GetTemplatesFor(opts.Kind, opts.Owner, opts.Repo)
+}
 
-		feat := spam.ExtractFeatures(issue, author, templates)
-		feats = append(feats, feat)
+// logPredictions appends predictions to the log read by the drift command
+func logPredictions(opts *SpamOpts, preds []classify.Prediction) error {
+	if len(preds) == 0 {
+		return nil
 	}
+	_ = os.MkdirAll(filepath.Dir(opts.LogPath), 0700)
+	return classify.AppendPredictions(opts.LogPath, preds)
+}
 
-	instances := classify.FeaturesToInstances(feats)
-	pred, err := tree.Predict(instances)
+func runDupes(opts *SpamOpts) error {
+	issues, err := spam.GetRecentItems(opts.Kind, opts.Owner, opts.Repo, time.Now().Add(-opts.Since), opts.Limit)
 	if err != nil {
 		return err
 	}
 
-	for i := 0; i < len(issues); i++ {
-		label := "not spam"
-		if pred.RowString(i) != "0" {
-			label = "spam"
+	byNumber := map[int]spam.Issue{}
+	dups := spam.NewDupIndex(opts.Threshold)
+	for _, issue := range issues {
+		byNumber[issue.Number] = issue
+		dups.Add(issue.Number, spam.IssueText(issue))
+	}
+
+	clusters := dups.Clusters()
+	if len(clusters) == 0 {
+		fmt.Println("no duplicates found")
+		return nil
+	}
+
+	for _, cluster := range clusters {
+		authors := map[string]bool{}
+		for _, num := range cluster {
+			authors[byNumber[num].Author.Login] = true
+		}
+		fmt.Printf("%d issues by %d authors: %q\n", len(cluster), len(authors), byNumber[cluster[0]].Title)
+		for _, num := range cluster {
+			issue := byNumber[num]
+			fmt.Printf("  #%d @%s\n", num, issue.Author.Login)
 		}
-		fmt.Printf("#%d: %s\n", opts.Numbers[i], label)
 	}
 	return nil
 }
 
 func runDownload(opts *SpamOpts) error {
-	datasetFound := true
-	_, err := os.Stat(opts.DataPath)
-	if errors.Is(err, os.ErrNotExist) {
-		_ = os.Mkdir("data", 0700)
-		_, err = os.Create(opts.DataPath)
-		if err != nil {
-			return err
-		}
-		datasetFound = false
+	if err := checkTrainable(opts); err != nil {
+		return err
+	}
+	if opts.SpamRatio <= 0 || opts.SpamRatio >= 1 {
+		return fmt.Errorf("--spam-ratio must be between 0 and 1")
 	}
 
-	var dataset *base.DenseInstances
-	if !datasetFound {
-		makeOpts := spam.MakeOpts{
-			Owner:   opts.Owner,
-			Repo:    opts.Repo,
-			Limit:   opts.Limit,
-			Verbose: opts.Verbose}
-		feats, err := spam.MakeDataset(makeOpts)
+	var dataset *classify.Dataset
+	_, err := os.Stat(opts.DataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		dataset, err = downloadDataset(opts)
 		if err != nil {
 			return err
 		}
-		dataset = classify.FeaturesToInstances(feats)
-
-		if err := base.SerializeInstancesToCSV(dataset, opts.DataPath); err != nil {
+		if err := writeDatasetFile(opts.DataPath, dataset); err != nil {
 			return err
 		}
-
 	} else {
-		dataset, err = base.ParseCSVToInstances(opts.DataPath, true)
+		dataset, err = classify.ReadDataset(opts.DataPath)
 		if err != nil {
 			return err
 		}
 	}
 
-	tree := ensemble.NewRandomForest(numTrees, 9)
-	if err := tree.Fit(dataset); err != nil {
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	fmt.Printf("class balance: %s\n", dataset.Balance())
+
+	// keep the params of a tuned model, but not its score, which was
+	// cross-validated on the old dataset
+	meta, err := classify.ReadMeta(opts.ModelPath)
+	if err != nil {
 		return err
 	}
-
-	predictions, err := tree.Predict(dataset)
+	meta.Folds, meta.Score = 0, 0
+	model, err := fitModel(opts, dataset, &meta, rng)
 	if err != nil {
 		return err
 	}
 
-	cm, err := evaluation.GetConfusionMatrix(dataset, predictions)
+	probs, err := model.Proba(dataset)
 	if err != nil {
 		return err
 	}
-	fmt.Println(evaluation.GetSummary(cm))
+	fmt.Println(evaluation.GetSummary(classify.ConfusionMatrix(dataset, probs, threshold(meta))))
+	printImportance(model)
 
 	// serialize model
-	return tree.Save(opts.ModelPath)
+	if err := model.Save(opts.ModelPath); err != nil {
+		return err
+	}
+	meta.Kind = opts.Kind
+	meta.Cols = dataset.Cols
+	meta.Seed = opts.Seed
+	meta.Balance = opts.Balance
+	meta.TrainedAt = time.Now().UTC()
+	return classify.WriteMeta(opts.ModelPath, meta)
+}
+
+// fitModel trains a model with the params in meta on a dataset, weighted
+// and rebalanced by the options. With --calibrate or --min-precision it
+// first holds out a stratified split of the dataset, and calibrates the
+// model's scores or chooses its threshold on it, saving them in meta.
+func fitModel(opts *SpamOpts, dataset *classify.Dataset, meta *classify.ModelMeta, rng *rand.Rand) (classify.Model, error) {
+	switch opts.Calibrate {
+	case "", classify.CalibrationNone, classify.CalibrationPlatt, classify.CalibrationIsotonic:
+	default:
+		return nil, fmt.Errorf("Invalid calibration %q, expected none, platt or isotonic", opts.Calibrate)
+	}
+	if opts.MinPrecision < 0 || opts.MinPrecision > 1 {
+		return nil, fmt.Errorf("--min-precision must be between 0 and 1")
+	}
+	calibrate := opts.Calibrate != "" && opts.Calibrate != classify.CalibrationNone
+	meta.Calibration, meta.OperatingPoint = nil, nil
+
+	train, heldOut := dataset, (*classify.Dataset)(nil)
+	if calibrate || opts.MinPrecision > 0 {
+		train, heldOut = classify.Split(dataset, classify.HoldOut, rng)
+		fmt.Printf("held out: %s\n", heldOut.Balance())
+	}
+
+	train, valid := classify.HoldOutValidation(train, meta.Params, rng)
+	if valid != nil {
+		fmt.Printf("early stopping on: %s\n", valid.Balance())
+	}
+	rebalanced, err := classify.Rebalance(classify.WeightConfidence(train), opts.Balance, rng)
+	if err != nil {
+		return nil, err
+	}
+	if rebalanced != dataset {
+		fmt.Printf("training on: %s\n", rebalanced.Balance())
+	}
+	model, err := classify.FitValidated(rebalanced, valid, meta.Params, rng)
+	if err != nil || heldOut == nil {
+		return model, err
+	}
+
+	probs, err := model.Proba(heldOut)
+	if err != nil {
+		return nil, err
+	}
+	if calibrate {
+		meta.Calibration, err = classify.Calibrate(opts.Calibrate, heldOut, probs)
+		if err != nil {
+			return nil, err
+		}
+		model = classify.Calibrated{Model: model, Calibration: meta.Calibration}
+		for i, prob := range probs {
+			probs[i] = meta.Calibration.Apply(prob)
+		}
+	}
+	if opts.MinPrecision > 0 {
+		meta.OperatingPoint = chooseThreshold(heldOut, probs, opts.MinPrecision)
+	}
+	return model, nil
+}
+
+// chooseThreshold prints the precision/recall curve of held-out rows, and
+// returns the threshold with the best recall at the min precision, or nil
+// if there isn't one
+func chooseThreshold(heldOut *classify.Dataset, probs []float64, minPrecision float64) *classify.OperatingPoint {
+	curve := classify.PRCurve(heldOut, probs)
+	point, ok := classify.ChooseThreshold(curve, minPrecision)
+
+	// show at most prCurveRows points, and always the chosen one
+	step := (len(curve) + prCurveRows - 1) / prCurveRows
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "threshold\tprecision\trecall\t")
+	for i, p := range curve {
+		chosen := ok && p == point
+		if i%step != 0 && i != len(curve)-1 && !chosen {
+			continue
+		}
+		mark := ""
+		if chosen {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%.3f\t%.3f\t%.3f\t%s\n", p.Threshold, p.Precision, p.Recall, mark)
+	}
+	w.Flush()
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "warning: no threshold has precision %.2f on held-out rows, so the policy's threshold is used\n", minPrecision)
+		return nil
+	}
+	fmt.Printf("threshold: %.3f (precision %.3f, recall %.3f)\n", point.Threshold, point.Precision, point.Recall)
+	_, spam := heldOut.ClassCounts()
+	if float64(spam) < 1/(1-minPrecision) {
+		fmt.Fprintf(os.Stderr, "warning: %d held-out spam rows are too few to measure precision %.2f reliably\n", spam, minPrecision)
+	}
+	return &classify.OperatingPoint{PRPoint: point, MinPrecision: minPrecision, Rows: len(heldOut.Rows)}
+}
+
+// threshold is the spam threshold to evaluate a model at
+func threshold(meta classify.ModelMeta) float64 {
+	if meta.OperatingPoint != nil {
+		return meta.OperatingPoint.Threshold
+	}
+	return 0.5
+}
+
+// makeDataset downloads items and extracts their features. Tests replace it
+// to avoid the network.
+var makeDataset = spam.MakeDataset
+
+// downloadDataset downloads a labeled dataset of the repo's items
+func downloadDataset(opts *SpamOpts) (*classify.Dataset, error) {
+	rules, err := opts.Config.CompileRules()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := getTemplates(opts)
+	if err != nil {
+		return nil, err
+	}
+	makeOpts := spam.MakeOpts{
+		Owner:     opts.Owner,
+		Repo:      opts.Repo,
+		Kind:      opts.Kind,
+		Limit:     opts.Limit,
+		SpamRatio: opts.SpamRatio,
+		Verbose:   opts.Verbose,
+		Rules:     rules,
+		Templates: templates}
+	feats, err := makeDataset(makeOpts)
+	if err != nil {
+		return nil, err
+	}
+	dataset := classify.NewDataset(classify.Columns(opts.Kind), feats)
+	dataset.SetProvenance(opts.Owner+"/"+opts.Repo, classify.SourceHeuristic, time.Now())
+	return dataset, nil
+}
+
+// printImportance prints the features of a boosted model by their share
+// of its splits' gain
+func printImportance(model classify.Model) {
+	if c, ok := model.(classify.Calibrated); ok {
+		model = c.Model
+	}
+	b, ok := model.(*classify.Boosted)
+	if !ok {
+		return
+	}
+	imp := b.Importance()
+	names := append([]string{}, b.Features...)
+	sort.SliceStable(names, func(i, j int) bool {
+		return imp[names[i]] > imp[names[j]]
+	})
+	fmt.Printf("%d boosted trees, feature importance:\n", len(b.Trees))
+	for _, name := range names {
+		if imp[name] > 0 {
+			fmt.Printf("  %-20s %.3f\n", name, imp[name])
+		}
+	}
+}
+
+// runTune cross-validates each combination of hyperparameters on the
+// local dataset, then trains and saves a model with the best
+func runTune(opts *SpamOpts) error {
+	if err := checkTrainable(opts); err != nil {
+		return err
+	}
+	dataset, err := classify.ReadDataset(opts.DataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s dataset for %s/%s not found, run download first", opts.Kind, opts.Owner, opts.Repo)
+	} else if err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	var candidates []classify.Params
+	switch opts.Search {
+	case "grid":
+		candidates = opts.Space.Grid(dataset.Cols)
+	case "random":
+		candidates = opts.Space.Sample(dataset.Cols, opts.Iterations, rng)
+	default:
+		return fmt.Errorf("Invalid search %q, expected grid or random", opts.Search)
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("No valid hyperparameters to search")
+	}
+
+	fmt.Printf("class balance: %s\n", dataset.Balance())
+	var best classify.Params
+	bestScore := -1.0
+	for _, params := range candidates {
+		score, err := classify.CrossValidate(dataset, params, opts.Folds, opts.Balance, rng)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%.3f  %s\n", score, params)
+		if score > bestScore {
+			best, bestScore = params, score
+		}
+	}
+	fmt.Printf("best: %.3f  %s\n", bestScore, best)
+
+	meta := classify.ModelMeta{
+		Params:  best,
+		Kind:    opts.Kind,
+		Cols:    dataset.Cols,
+		Seed:    opts.Seed,
+		Balance: opts.Balance,
+		Folds:   opts.Folds,
+		Score:   bestScore,
+	}
+	model, err := fitModel(opts, dataset, &meta, rng)
+	if err != nil {
+		return err
+	}
+	if err := model.Save(opts.ModelPath); err != nil {
+		return err
+	}
+	meta.TrainedAt = time.Now().UTC()
+	return classify.WriteMeta(opts.ModelPath, meta)
+}
+
+// runDrift updates the human labels of recent predictions, then compares
+// their features and precision to the model's training set
+func runDrift(opts *SpamOpts) error {
+	if err := checkTrainable(opts); err != nil {
+		return err
+	}
+	preds, err := classify.ReadPredictions(opts.LogPath)
+	if err != nil {
+		return err
+	}
+	since := time.Now().Add(-opts.Since)
+	recent := []classify.Prediction{}
+	for i := range preds {
+		if preds[i].Time.Before(since) {
+			continue
+		}
+		label, err := humanLabel(opts, preds[i])
+		if err != nil {
+			// deleted items keep their last label
+			if opts.Verbose {
+				fmt.Fprintf(os.Stderr, "Error getting label of %s #%d: %s\n", preds[i].Kind, preds[i].Number, err)
+			}
+			label = preds[i].Label
+		}
+		if label != preds[i].Label {
+			now := time.Now().UTC()
+			preds[i].Label, preds[i].LabeledAt = label, &now
+		}
+		recent = append(recent, preds[i])
+	}
+	if len(recent) == 0 {
+		fmt.Printf("no %s predictions logged in the last %s\n", opts.Kind, opts.Since)
+		return nil
+	}
+	if err := classify.WritePredictions(opts.LogPath, preds); err != nil {
+		return err
+	}
+
+	train, err := classify.ReadDataset(opts.DataPath)
+	if err != nil {
+		return err
+	}
+	model, meta, err := classify.LoadModel(opts.ModelPath)
+	if err != nil {
+		return err
+	}
+	probs, err := model.Proba(train)
+	if err != nil {
+		return err
+	}
+
+	bounds := opts.Config.Drift
+	reasons := []string{}
+	fmt.Printf("%d predictions since %s, model trained %s\n", len(recent), since.Format("2006-01-02"), meta.TrainedAt.Format("2006-01-02"))
+
+	precision, labeled := classify.Precision(recent)
+	trainPrecision := classify.TrainPrecision(train, probs, opts.Config.Thresholds.Spam)
+	if labeled == 0 {
+		fmt.Printf("precision: no spam verdicts labeled yet (training %.2f)\n", trainPrecision)
+	} else {
+		fmt.Printf("precision: %.2f of %d labeled spam verdicts (training %.2f)\n", precision, labeled, trainPrecision)
+		if labeled >= bounds.MinPredictions && precision < bounds.MinPrecision {
+			reasons = append(reasons, fmt.Sprintf("precision %.2f is below %.2f", precision, bounds.MinPrecision))
+		}
+	}
+
+	fmt.Println("feature drift (PSI):")
+	for _, d := range classify.Drift(train, recent) {
+		if !opts.Verbose && d.PSI < 0.1 {
+			continue
+		}
+		fmt.Printf("  %-16s %.3f\n", d.Col, d.PSI)
+		if len(recent) >= bounds.MinPredictions && d.PSI > bounds.MaxPSI {
+			reasons = append(reasons, fmt.Sprintf("%s PSI %.2f is above %.2f", d.Col, d.PSI, bounds.MaxPSI))
+		}
+	}
+
+	if len(reasons) == 0 {
+		fmt.Println("no retraining needed")
+		return nil
+	}
+	fmt.Printf("retraining recommended: %s\n", strings.Join(reasons, "; "))
+	if !opts.Retrain && !bounds.Retrain {
+		return nil
+	}
+
+	// keep the old dataset, and retrain on a fresh download with the same
+	// settings and the labels maintainers confirmed
+	opts.Limit = downloadLimit
+	opts.SpamRatio = 0.5
+	opts.Balance = meta.Balance
+	opts.Seed = meta.Seed
+	if meta.Calibration != nil {
+		opts.Calibrate = meta.Calibration.Method
+	}
+	if meta.OperatingPoint != nil {
+		opts.MinPrecision = meta.OperatingPoint.MinPrecision
+	}
+	return retrain(opts)
+}
+
+// retrain moves the dataset to a .bak file and trains on a fresh download
+// merged with the old dataset's confirmed rows. If retraining fails, the
+// old dataset is restored.
+func retrain(opts *SpamOpts) (err error) {
+	old, err := classify.ReadDataset(opts.DataPath)
+	if err != nil {
+		return err
+	}
+	backup := opts.DataPath + ".bak"
+	if err := os.Rename(opts.DataPath, backup); err != nil {
+		return err
+	}
+	fmt.Printf("moved old dataset to %s, retraining\n", backup)
+	defer func() {
+		if err == nil {
+			return
+		}
+		if restoreErr := os.Rename(backup, opts.DataPath); restoreErr != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring %s: %s\n", opts.DataPath, restoreErr)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "restored old dataset to %s\n", opts.DataPath)
+	}()
+
+	fresh, err := downloadDataset(opts)
+	if err != nil {
+		return err
+	}
+	confirmed := old.Confirmed()
+	merged, err := classify.Merge(fresh, confirmed)
+	if err != nil {
+		return fmt.Errorf("Error keeping confirmed rows: %s", err)
+	}
+	dataset, _ := merged.Dedupe()
+	fmt.Printf("kept %d confirmed rows from the old dataset\n", len(confirmed.Rows))
+	if err := writeDatasetFile(opts.DataPath, dataset); err != nil {
+		return err
+	}
+	return runDownload(opts)
+}
+
+// humanLabel is "spam" or "not spam" if a maintainer has labeled a
+// predicted item, e.g. by removing a spam label we added
+func humanLabel(opts *SpamOpts, pred classify.Prediction) (string, error) {
+	var isSpam bool
+	if pred.Kind == spam.KindComment {
+		comment, err := spam.GetCommentByURL(pred.URL)
+		if err != nil {
+			return "", err
+		}
+		isSpam = comment.IsSpam
+	} else {
+		labels, err := spam.GetLabels(pred.Kind, opts.Owner, opts.Repo, pred.Number)
+		if err != nil {
+			return "", err
+		}
+		for _, label := range labels {
+			for _, spamLabel := range opts.Config.Labels.Spam {
+				if strings.EqualFold(label, spamLabel) {
+					isSpam = true
+				}
+			}
+		}
+	}
+
+	// the spam label or hidden comment we added was removed
+	markAction := "label"
+	if pred.Kind == spam.KindComment {
+		markAction = "hide"
+	}
+	marked := false
+	for _, action := range opts.Config.Actions.Spam {
+		marked = marked || action == markAction
+	}
+
+	switch {
+	case isSpam:
+		return "spam", nil
+	case pred.Applied && pred.Verdict == "spam" && marked:
+		return "not spam", nil
+	default:
+		return pred.Label, nil
+	}
+}
+
+// runSyncFeedback reads maintainers' spam labeling since the last sync, and
+// saves it to the dataset as high confidence labels
+func runSyncFeedback(opts *SpamOpts) error {
+	dataset, err := classify.ReadDataset(opts.DataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s dataset for %s/%s not found, run download first", opts.Kind, opts.Owner, opts.Repo)
+	} else if err != nil {
+		return err
+	}
+	if len(dataset.Rows) > 0 && dataset.Meta[0].Number == 0 {
+		fmt.Fprintln(os.Stderr, "warning: the dataset has no item numbers, so feedback is added as new rows. Download it again to correct existing rows.")
+	}
+
+	syncPath := kindPath(opts.Kind, filepath.Join("data", fmt.Sprintf("%s-%s-sync.json", opts.Owner, opts.Repo)))
+	since, err := readLastSync(syncPath)
+	if err != nil {
+		return err
+	}
+	if opts.Since > 0 {
+		since = time.Now().Add(-opts.Since)
+	}
+
+	started := time.Now().UTC()
+	self, err := spam.GetViewer()
+	if err != nil {
+		return err
+	}
+	feedback, err := spam.GetFeedback(opts.Kind, opts.Owner, opts.Repo, opts.Config.Labels.Spam, self, since, opts.Limit)
+	if err != nil {
+		return err
+	}
+
+	// items missing from the dataset are added with the features a
+	// detector would score
+	var det *detector.Detector
+	corrected, added := 0, 0
+	for _, f := range feedback {
+		label, verdict := 0, "not spam"
+		if f.IsSpam {
+			label, verdict = 1, "spam"
+		}
+
+		i, err := dataset.Find(opts.Owner+"/"+opts.Repo, f.Number)
+		if err != nil {
+			return err
+		}
+		if i < 0 {
+			if det == nil {
+				if det, err = detector.New(nil, newSource(opts), detector.Options{Kind: opts.Kind, Config: &opts.Config}); err != nil {
+					return err
+				}
+			}
+			issue, err := spam.GetItemByNumber(opts.Kind, opts.Owner, opts.Repo, f.Number)
+			if err == nil {
+				var feat spam.Features
+				feat, err = det.Features(context.Background(), issue)
+				if err == nil {
+					row := classify.NewDataset(dataset.Cols, []spam.Features{feat})
+					row.SetProvenance(opts.Owner+"/"+opts.Repo, classify.SourceFeedback, time.Now())
+					dataset.Append(row.Rows[0], row.Meta[0])
+					i = len(dataset.Rows) - 1
+					added++
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error adding #%d: %s\n", f.Number, err)
+				continue
+			}
+		} else if dataset.Label(i) != label {
+			corrected++
+		}
+
+		dataset.SetLabel(i, label)
+		dataset.Meta[i].Confidence = classify.ConfidenceHigh
+		dataset.Meta[i].Source = classify.SourceFeedback
+		if opts.Verbose {
+			fmt.Printf("#%d: %s (%s by @%s)\n", f.Number, verdict, f.Event, f.Actor)
+		}
+	}
+
+	if err := classify.WriteDataset(opts.DataPath, dataset); err != nil {
+		return err
+	}
+	fmt.Printf("%d maintainer labels since %s: %d corrected, %d added to the dataset\n",
+		len(feedback), since.Format("2006-01-02"), corrected, added)
+	return writeLastSync(syncPath, started)
+}
+
+type syncState struct {
+	LastSync time.Time `json:"last_sync"`
+}
+
+// readLastSync reads when feedback was last synced
+func readLastSync(path string) (time.Time, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Now().Add(-feedbackWindow), nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	var state syncState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return time.Time{}, fmt.Errorf("Invalid sync state %s: %s", path, err)
+	}
+	return state.LastSync, nil
+}
+
+func writeLastSync(path string, t time.Time) error {
+	b, err := json.Marshal(syncState{LastSync: t})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// runExport saves the model in the classify/forest package's JSON format
+func runExport(opts *SpamOpts) error {
+	if err := checkTrainable(opts); err != nil {
+		return err
+	}
+	model, meta, err := classify.LoadModel(opts.ModelPath)
+	if err != nil {
+		return err
+	}
+	if calibrated, ok := model.(classify.Calibrated); ok {
+		fmt.Fprintln(os.Stderr, "warning: the forest format has no calibration, so exported scores aren't calibrated")
+		model = calibrated.Model
+	}
+	rf, ok := model.(classify.RandomForest)
+	if !ok {
+		return fmt.Errorf("only forest and bagging models can be exported, not %s", meta.Classifier)
+	}
+	cols := meta.Cols
+	if len(cols) == 0 {
+		cols = classify.Columns(opts.Kind)
+	}
+	exported, err := classify.ExportForest(rf.RandomForest, cols)
+	if err != nil {
+		return err
+	}
+
+	output := opts.Output
+	if output == "" {
+		output = strings.TrimSuffix(opts.ModelPath, filepath.Ext(opts.ModelPath)) + "-forest.json"
+	}
+	if err := exported.Save(output); err != nil {
+		return err
+	}
+	fmt.Printf("exported %d trees to %s\n", len(exported.Trees), output)
+	return nil
+}
+
+// runImport converts trees exported by script/sklearn_export.py to the
+// classify/forest package's JSON format, checking their features
+func runImport(opts *SpamOpts, path string) error {
+	imported, err := classify.LoadSklearn(path, classify.Columns(opts.Kind))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	output := opts.Output
+	if output == "" {
+		base := opts.ModelPath
+		if filepath.Ext(base) == ".json" {
+			base = opts.DataPath
+		}
+		output = strings.TrimSuffix(base, filepath.Ext(base)) + "-imported.json"
+	}
+	if filepath.Ext(output) != ".json" {
+		return fmt.Errorf("%s: imported models are saved as .json", output)
+	}
+	if err := imported.Save(output); err != nil {
+		return err
+	}
+	fmt.Printf("imported %d trees on %d features to %s\n", len(imported.Trees), len(imported.Features), output)
+	fmt.Printf("set model: %s in the policy to classify with it\n", output)
+	return nil
+}
+
+// runReport benchmarks the models of the report spec on a dataset. Flags
+// override the spec's settings.
+func runReport(cmd *cobra.Command, opts *SpamOpts, path string) error {
+	flags := cmd.Flags()
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+	spec := classify.DefaultReportSpec
+	if opts.SpecPath != "" {
+		if spec, err = classify.LoadReportSpec(opts.SpecPath); err != nil {
+			return err
+		}
+	}
+	if opts.SpecPath == "" || flags.Changed("seed") {
+		spec.Seed = opts.Seed
+	}
+	if opts.SpecPath == "" || flags.Changed("test-size") {
+		spec.TestSize = opts.TestSize
+	}
+	if opts.SpecPath == "" || flags.Changed("balance") {
+		spec.Balance = opts.Balance
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = classify.ReportMarkdown
+		if ext := filepath.Ext(opts.Output); ext == ".html" || ext == ".htm" {
+			format = classify.ReportHTML
+		}
+	}
+	if format != classify.ReportMarkdown && format != classify.ReportHTML {
+		return fmt.Errorf("Unknown report format %q, expected markdown or html", format)
+	}
+
+	fmt.Fprintf(os.Stderr, "comparing %d models on %d feature sets of %s\n", len(spec.Models), len(spec.FeatureSets), path)
+	report, err := classify.RunReport(dataset, spec)
+	if err != nil {
+		return err
+	}
+	if opts.Output == "" {
+		return report.Write(os.Stdout, format)
+	}
+	file, err := os.Create(opts.Output)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(file, format); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", opts.Output)
+	return nil
+}
+
+// checkTrainable checks the model isn't an exported forest, which can only
+// be used to classify
+func checkTrainable(opts *SpamOpts) error {
+	if filepath.Ext(opts.ModelPath) == ".json" {
+		return fmt.Errorf("%s is an exported forest; set the policy's model to a .gob path to train", opts.ModelPath)
+	}
+	return nil
+}
+
+func runDatasetInfo(opts *SpamOpts, path string) error {
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d rows, %d features\n", path, len(dataset.Rows), len(dataset.Cols)-1)
+	fmt.Printf("class balance: %s\n", dataset.Balance())
+
+	confirmed := 0
+	for _, meta := range dataset.Meta {
+		if meta.Confidence == classify.ConfidenceHigh {
+			confirmed++
+		}
+	}
+	if confirmed > 0 {
+		fmt.Printf("confirmed by maintainers: %d\n", confirmed)
+	}
+	fmt.Printf("repos: %s\n", countMeta(dataset, func(m classify.RowMeta) string { return m.Repo }))
+	fmt.Printf("label sources: %s\n", countMeta(dataset, func(m classify.RowMeta) string { return m.Source }))
+
+	var first, last time.Time
+	for _, meta := range dataset.Meta {
+		if meta.FetchedAt.IsZero() {
+			continue
+		}
+		if first.IsZero() || meta.FetchedAt.Before(first) {
+			first = meta.FetchedAt
+		}
+		if meta.FetchedAt.After(last) {
+			last = meta.FetchedAt
+		}
+	}
+	if !first.IsZero() {
+		fmt.Printf("fetched: %s to %s\n", first.Format("2006-01-02"), last.Format("2006-01-02"))
+	}
+	if _, removed := dataset.Dedupe(); removed > 0 {
+		fmt.Printf("duplicate rows: %d\n", removed)
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "column\tmin\tmax\tmean\tstd\tmean spam\tmean not spam")
+	for _, s := range dataset.Stats() {
+		if s.Col == "is_spam" {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%.4g\t%.4g\t%.4g\t%.4g\t%.4g\t%.4g\n",
+			s.Col, s.Min, s.Max, s.Mean, s.Std, s.SpamMean, s.NotSpamMean)
+	}
+	return w.Flush()
+}
+
+// countMeta counts rows by a meta field, most common first. Rows without
+// it are counted as unknown, e.g. in datasets from before provenance.
+func countMeta(d *classify.Dataset, field func(classify.RowMeta) string) string {
+	counts := map[string]int{}
+	for _, meta := range d.Meta {
+		key := field(meta)
+		if key == "" {
+			key = "unknown"
+		}
+		counts[key]++
+	}
+	keys := []string{}
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := []string{}
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s %d", key, counts[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runDatasetMerge(opts *SpamOpts, paths []string) error {
+	datasets := []*classify.Dataset{}
+	for _, path := range paths {
+		dataset, err := classify.ReadDataset(path)
+		if err != nil {
+			return err
+		}
+		datasets = append(datasets, dataset)
+	}
+	merged, err := classify.Merge(datasets...)
+	if err != nil {
+		return err
+	}
+
+	if err := writeDatasetFile(opts.Output, merged); err != nil {
+		return err
+	}
+	fmt.Printf("merged %d rows into %s\n", len(merged.Rows), opts.Output)
+	return nil
+}
+
+func runDatasetDedupe(opts *SpamOpts, path string) error {
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+	deduped, removed := dataset.Dedupe()
+
+	output := opts.Output
+	if output == "" {
+		output = path
+	}
+	if err := writeDatasetFile(output, deduped); err != nil {
+		return err
+	}
+	fmt.Printf("removed %d duplicate rows, %d left\n", removed, len(deduped.Rows))
+	return nil
+}
+
+func runDatasetSplit(opts *SpamOpts, path string) error {
+	if opts.TestSize <= 0 || opts.TestSize >= 1 {
+		return fmt.Errorf("test-size must be between 0 and 1")
+	}
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	train, test := classify.Split(dataset, opts.TestSize, rng)
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, part := range []struct {
+		name    string
+		dataset *classify.Dataset
+	}{{"train", train}, {"test", test}} {
+		output := fmt.Sprintf("%s-%s.csv", base, part.name)
+		if err := writeDatasetFile(output, part.dataset); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", output, part.dataset.Balance())
+	}
+	return nil
+}
+
+func runDatasetExport(opts *SpamOpts, path string) error {
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+	output := opts.Output
+	if output == "" {
+		output = strings.TrimSuffix(path, filepath.Ext(path)) + ".jsonl"
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := classify.WriteJSONL(f, dataset); err != nil {
+		return err
+	}
+	fmt.Printf("exported %d rows to %s\n", len(dataset.Rows), output)
+	return f.Close()
+}
+
+// writeDatasetFile writes a dataset, creating its directory
+func writeDatasetFile(path string, d *classify.Dataset) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return classify.WriteDataset(path, d)
+}
+
+func runDatasetRows(opts *SpamOpts, path string) error {
+	switch opts.Label {
+	case "", "spam", "not-spam":
+	default:
+		return fmt.Errorf("invalid label %q: must be spam or not-spam", opts.Label)
+	}
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "item\tauthor\tlabel\tsource\tfetched")
+	for i, meta := range dataset.Meta {
+		label := "not-spam"
+		if dataset.Label(i) == 1 {
+			label = "spam"
+		}
+		if (opts.Label != "" && label != opts.Label) || (opts.Source != "" && meta.Source != opts.Source) {
+			continue
+		}
+		item := meta.ID()
+		if item == "" {
+			item = fmt.Sprintf("row %d", i+1)
+		}
+		author := "-"
+		if meta.Author != "" {
+			author = "@" + meta.Author
+		}
+		fetched := "-"
+		if !meta.FetchedAt.IsZero() {
+			fetched = meta.FetchedAt.Format("2006-01-02")
+		}
+		source := meta.Source
+		if source == "" {
+			source = "-"
+		}
+		if meta.Confidence == classify.ConfidenceHigh {
+			source += " (confirmed)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", item, author, label, source, fetched)
+	}
+	return w.Flush()
+}
+
+// parseItemRef parses an item as OWNER/REPO#NUMBER, #NUMBER or NUMBER.
+// The repo is empty if it isn't given.
+func parseItemRef(ref string) (repo string, number int, err error) {
+	numberArg := ref
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		repo, numberArg = ref[:i], ref[i+1:]
+	}
+	number, err = strconv.Atoi(numberArg)
+	if err != nil || number <= 0 || (repo != "" && strings.Count(repo, "/") != 1) {
+		return "", 0, fmt.Errorf("invalid item %q, expected OWNER/REPO#NUMBER or NUMBER", ref)
+	}
+	return repo, number, nil
+}
+
+func runDatasetLabel(opts *SpamOpts, itemArg, labelArg, path string) error {
+	repo, number, err := parseItemRef(itemArg)
+	if err != nil {
+		return err
+	}
+	var label int
+	switch labelArg {
+	case "spam":
+		label = 1
+	case "not-spam":
+		label = 0
+	default:
+		return fmt.Errorf("invalid label %q: must be spam or not-spam", labelArg)
+	}
+
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+	i, err := dataset.Find(repo, number)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return fmt.Errorf("%s is not in %s", itemArg, path)
+	}
+	dataset.SetLabel(i, label)
+	dataset.Meta[i].Confidence = classify.ConfidenceHigh
+	dataset.Meta[i].Source = classify.SourceManual
+	if err := classify.WriteDataset(path, dataset); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s\n", dataset.Meta[i].ID(), labelArg)
+	return nil
+}
+
+// runDatasetMake downloads a dataset with progress logging
+func runDatasetMake(opts *SpamOpts) error {
+	if opts.SpamRatio <= 0 || opts.SpamRatio >= 1 {
+		return fmt.Errorf("--spam-ratio must be between 0 and 1")
+	}
+	opts.Verbose = true
+	dataset, err := downloadDataset(opts)
+	if err != nil {
+		return err
+	}
+
+	output := opts.Output
+	if output == "" {
+		output = opts.DataPath
+	}
+	if err := writeDatasetFile(output, dataset); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s\n", output, dataset.Balance())
+	return nil
+}
+
+// runDatasetTrain trains a model on part of a dataset and evaluates it on
+// a stratified held-out set
+func runDatasetTrain(opts *SpamOpts, path string) error {
+	if opts.TestSize <= 0 || opts.TestSize >= 1 {
+		return fmt.Errorf("test-size must be between 0 and 1")
+	}
+	if opts.Params.Classifier != classify.ClassifierForest {
+		opts.Params.Features = 0
+	}
+	if opts.Output != "" && filepath.Ext(opts.Output) == ".json" {
+		return fmt.Errorf("%s: models are saved as .gob; use export to convert them", opts.Output)
+	}
+	dataset, err := classify.ReadDataset(path)
+	if err != nil {
+		return err
+	}
+	if err := opts.Params.Validate(dataset.Cols); err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	train, test := classify.Split(dataset, opts.TestSize, rng)
+	if len(test.Rows) == 0 {
+		return fmt.Errorf("the test set is empty, increase --test-size")
+	}
+	fmt.Printf("train: %s\n", train.Balance())
+	fmt.Printf("test: %s\n", test.Balance())
+
+	meta := classify.ModelMeta{
+		Params:  opts.Params,
+		Kind:    opts.Kind,
+		Cols:    dataset.Cols,
+		Seed:    opts.Seed,
+		Balance: opts.Balance,
+	}
+	model, err := fitModel(opts, train, &meta, rng)
+	if err != nil {
+		return err
+	}
+	probs, err := model.Proba(test)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", opts.Params)
+	fmt.Println(evaluation.GetSummary(classify.ConfusionMatrix(test, probs, threshold(meta))))
+	fmt.Printf("log loss: %.4f\n", classify.LogLoss(test, probs))
+	printImportance(model)
+
+	if opts.Output == "" {
+		return nil
+	}
+	if err := model.Save(opts.Output); err != nil {
+		return err
+	}
+	meta.TrainedAt = time.Now().UTC()
+	return classify.WriteMeta(opts.Output, meta)
 }
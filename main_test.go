@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meiji163/gh-spam/classify"
+	"github.com/meiji163/gh-spam/spam"
+)
+
+// runCmd runs the CLI on a checkout in dir, so the policy and templates
+// don't come from GitHub
+func runCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := rootCmd()
+	cmd.SetArgs(append([]string{"-R", "cli/cli", "--local=" + dir}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("%v: %s", args, err)
+	}
+}
+
+// writeSynthetic writes a dataset where spam comes from young accounts
+// with few followers
+func writeSynthetic(t *testing.T, path string, rows int) *classify.Dataset {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	d := &classify.Dataset{Cols: []string{"age", "followers", "template_sim", "is_spam"}}
+	for i := 0; i < rows; i++ {
+		row := []float64{float64(rng.Intn(1000)), float64(rng.Intn(50)), rng.Float64(), 0}
+		if i%3 == 0 {
+			row[0] /= 20
+			row[1] /= 10
+			row[3] = 1
+		}
+		d.Append(row, classify.RowMeta{Repo: "cli/cli", Number: i + 1, Author: fmt.Sprintf("user%d", i)})
+	}
+	if err := classify.WriteDataset(path, d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDatasetMake(t *testing.T) {
+	dir := t.TempDir()
+	defer func(f func(spam.MakeOpts) ([]spam.Features, error)) { makeDataset = f }(makeDataset)
+	makeDataset = func(opts spam.MakeOpts) ([]spam.Features, error) {
+		if opts.Owner != "cli" || opts.Repo != "cli" || opts.Limit != 10 {
+			t.Errorf("got make options %+v", opts)
+		}
+		return []spam.Features{
+			{Kind: spam.KindIssue, Number: 1, Author: "monalisa", Followers: 30},
+			{Kind: spam.KindIssue, Number: 2, Author: "spammer", IsSpam: 1},
+		}, nil
+	}
+
+	path := filepath.Join(dir, "data", "cli-cli.csv")
+	runCmd(t, dir, "dataset", "make", "--limit", "10", "-o", path)
+
+	d, err := classify.ReadDataset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Rows) != 2 || len(d.Cols) != len(classify.Columns(spam.KindIssue)) {
+		t.Fatalf("got %d rows with %d columns", len(d.Rows), len(d.Cols))
+	}
+	if meta := d.Meta[1]; meta.ID() != "cli/cli#2" || meta.Author != "spammer" || meta.Source != classify.SourceHeuristic || meta.FetchedAt.IsZero() {
+		t.Errorf("got meta %+v", meta)
+	}
+	if d.Label(0) != 0 || d.Label(1) != 1 {
+		t.Errorf("got labels %d, %d", d.Label(0), d.Label(1))
+	}
+}
+
+func TestDatasetTrain(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.csv")
+	writeSynthetic(t, data, 150)
+
+	model := filepath.Join(dir, "model.gob")
+	runCmd(t, dir, "dataset", "train", data, "--trees", "5", "--features-per-tree", "2", "-o", model)
+
+	_, meta, err := classify.LoadModel(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Trees != 5 || len(meta.Cols) != 4 {
+		t.Errorf("got meta %+v", meta)
+	}
+
+	runCmd(t, dir, "dataset", "train", data, "--classifier", "boosting", "--trees", "50", "-o", model)
+	boosted, meta, err := classify.LoadModel(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := boosted.(*classify.Boosted); !ok || meta.Classifier != classify.ClassifierBoosting {
+		t.Errorf("got %T model with meta %+v", boosted, meta)
+	}
+
+	runCmd(t, dir, "dataset", "train", data, "--trees", "5", "--features-per-tree", "2", "--calibrate", "platt", "-o", model)
+	calibrated, meta, err := classify.LoadModel(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := calibrated.(classify.Calibrated); !ok || meta.Calibration == nil || meta.Calibration.Method != classify.CalibrationPlatt {
+		t.Errorf("got %T model with calibration %+v", calibrated, meta.Calibration)
+	}
+
+	runCmd(t, dir, "dataset", "train", data, "--classifier", "boosting", "--trees", "50", "--min-precision", "0.9", "-o", model)
+	_, meta, err = classify.LoadModel(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op := meta.OperatingPoint; op == nil || op.Precision < 0.9 || op.MinPrecision != 0.9 {
+		t.Errorf("got operating point %+v, want precision at least 0.9", op)
+	}
+}
+
+func TestDatasetCommands(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.csv")
+	d := writeSynthetic(t, data, 40)
+
+	runCmd(t, dir, "dataset", "info", data)
+	runCmd(t, dir, "dataset", "split", data, "--test-size", "0.25")
+	merged := filepath.Join(dir, "merged.csv")
+	runCmd(t, dir, "dataset", "merge", "-o", merged, filepath.Join(dir, "data-train.csv"), filepath.Join(dir, "data-test.csv"), data)
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"-R", "cli/cli", "--local=" + dir, "dataset", "merge", data, merged})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected merge without -o to fail rather than overwrite the repo's dataset")
+	}
+	runCmd(t, dir, "dataset", "dedupe", merged)
+	runCmd(t, dir, "dataset", "label", "#2", "spam", merged)
+
+	got, err := classify.ReadDataset(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Rows) != len(d.Rows) {
+		t.Errorf("got %d rows after merging and deduping, want %d", len(got.Rows), len(d.Rows))
+	}
+	i, _ := got.Find("", 2)
+	if got.Label(i) != 1 || got.Meta[i].Source != classify.SourceManual {
+		t.Errorf("got label %d from %q, want a manual spam label", got.Label(i), got.Meta[i].Source)
+	}
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.csv")
+	writeSynthetic(t, data, 150)
+
+	spec := filepath.Join(dir, "report.yml")
+	if err := os.WriteFile(spec, []byte(`
+models:
+  - name: small forest
+    classifier: forest
+    trees: 5
+    features: 2
+  - classifier: boosting
+    trees: 20
+    max_depth: 2
+feature_sets:
+  - name: all
+  - name: account
+    include: [age, followers]
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "report.html")
+	runCmd(t, dir, "report", data, "--spec", spec, "--seed", "3", "-o", out)
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(b)
+	for _, want := range []string{"<h2>small forest on account</h2>", "<h2>boosting trees=20 depth=2 on all</h2>", "with seed 3", "<svg"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
+
+func TestRetrain(t *testing.T) {
+	dir := t.TempDir()
+	opts := &SpamOpts{
+		Owner:     "cli",
+		Repo:      "cli",
+		Kind:      spam.KindIssue,
+		DataPath:  filepath.Join(dir, "cli-cli.csv"),
+		ModelPath: filepath.Join(dir, "cli-cli.gob"),
+		Local:     dir,
+		Config:    spam.DefaultConfig(),
+		Limit:     60,
+		SpamRatio: 0.5,
+		Seed:      1,
+	}
+
+	// a maintainer confirmed #1000 is spam, which a fresh download misses
+	fresh := []spam.Features{}
+	for i := 1; i <= 60; i++ {
+		f := spam.Features{Kind: spam.KindIssue, Number: i, Followers: 20 + i%7, AccountAge: 500}
+		if i%2 == 0 {
+			f.Followers, f.AccountAge, f.IsSpam = 0, 2, 1
+		}
+		fresh = append(fresh, f)
+	}
+	old := classify.NewDataset(classify.Columns(spam.KindIssue), []spam.Features{fresh[0], {Kind: spam.KindIssue, Number: 1000, IsSpam: 1}})
+	old.SetProvenance("cli/cli", classify.SourceHeuristic, time.Now())
+	old.Meta[1].Confidence = classify.ConfidenceHigh
+	if err := classify.WriteDataset(opts.DataPath, old); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(f func(spam.MakeOpts) ([]spam.Features, error)) { makeDataset = f }(makeDataset)
+	makeDataset = func(spam.MakeOpts) ([]spam.Features, error) {
+		return nil, fmt.Errorf("rate limited")
+	}
+	if err := retrain(opts); err == nil {
+		t.Fatal("expected the download error")
+	}
+	if d, err := classify.ReadDataset(opts.DataPath); err != nil || len(d.Rows) != 2 {
+		t.Fatalf("expected the old dataset to be restored, got %v", err)
+	}
+
+	makeDataset = func(spam.MakeOpts) ([]spam.Features, error) {
+		return fresh, nil
+	}
+	if err := retrain(opts); err != nil {
+		t.Fatal(err)
+	}
+	d, err := classify.ReadDataset(opts.DataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := d.Find("cli/cli", 1000)
+	if err != nil || len(d.Rows) != len(fresh)+1 || i < 0 || d.Meta[i].Confidence != classify.ConfidenceHigh {
+		t.Errorf("got %d rows, want the fresh download and the confirmed row", len(d.Rows))
+	}
+	if _, err := os.Stat(opts.DataPath + ".bak"); err != nil {
+		t.Errorf("expected the old dataset to be kept: %s", err)
+	}
+}
+
+func TestParseItemRef(t *testing.T) {
+	tests := []struct {
+		ref    string
+		repo   string
+		number int
+	}{
+		{"4894", "", 4894},
+		{"#4894", "", 4894},
+		{"cli/cli#4894", "cli/cli", 4894},
+		{"cli#4894", "", 0},
+		{"cli/cli#x", "", 0},
+		{"-1", "", 0},
+	}
+	for _, tt := range tests {
+		repo, number, err := parseItemRef(tt.ref)
+		if repo != tt.repo || number != tt.number || (err != nil) != (tt.number == 0) {
+			t.Errorf("parseItemRef(%q) = %q, %d, %v", tt.ref, repo, number, err)
+		}
+	}
+}
+
+func TestPrintImportance(t *testing.T) {
+	b := &classify.Boosted{Features: []string{"age", "followers"}, Trees: []classify.BoostTree{{}}, Gain: []float64{3, 1}}
+	for _, model := range []classify.Model{b, classify.Calibrated{Model: b, Calibration: &classify.Calibration{}}} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := os.Stdout
+		os.Stdout = w
+		printImportance(model)
+		os.Stdout = stdout
+		w.Close()
+		out, _ := io.ReadAll(r)
+		if !strings.Contains(string(out), "1 boosted trees") || !strings.Contains(string(out), "age") {
+			t.Errorf("%T: got %q, want the boosted model's importance", model, out)
+		}
+	}
+}
+
+func TestDatasetFilesOnly(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.csv")
+	writeSynthetic(t, data, 20)
+
+	// outside a checkout, without -R or --local, the repo can't be resolved
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for _, args := range [][]string{
+		{"dataset", "info", data},
+		{"dataset", "label", "2", "spam", data},
+	} {
+		cmd := rootCmd()
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Errorf("%v: %s", args, err)
+		}
+	}
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"dataset", "info"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected dataset info without a file to need the repo")
+	}
+}
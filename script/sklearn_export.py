@@ -0,0 +1,32 @@
+# Exports a scikit-learn decision tree or random forest for `gh-spam import`
+
+import json
+
+
+def export(clf, path):
+    """Write the trees of a DecisionTreeClassifier or RandomForestClassifier
+    fit on a DataFrame of dataset columns, with is_spam as the target."""
+    if not hasattr(clf, "feature_names_in_"):
+        raise ValueError("fit the classifier on a DataFrame so its features are named")
+    if [int(c) for c in clf.classes_] != [0, 1]:
+        raise ValueError("the target must be is_spam, with classes 0 and 1")
+
+    trees = []
+    for est in getattr(clf, "estimators_", [clf]):
+        t = est.tree_
+        trees.append({
+            "children_left": t.children_left.tolist(),
+            "children_right": t.children_right.tolist(),
+            "feature": t.feature.tolist(),
+            "threshold": t.threshold.tolist(),
+            # class weights or fractions of the rows reaching each node
+            "value": t.value[:, 0, :].tolist(),
+        })
+
+    with open(path, "w") as f:
+        json.dump({
+            "format": "sklearn-trees",
+            "feature_names": list(clf.feature_names_in_),
+            "classes": [0, 1],
+            "trees": trees,
+        }, f)
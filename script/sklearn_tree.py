@@ -4,9 +4,14 @@ from matplotlib import pyplot as plt
 import pandas as pd
 from sklearn import tree
 
-dataset = pd.read_csv("cli-cli_data.csv")
+from sklearn_export import export
 
-features = list(dataset.columns)[:-1] 
+# gh-spam dataset export data/cli-cli.csv
+dataset = pd.read_json("data/cli-cli.jsonl", lines=True)
+
+# the provenance columns describe rows and aren't features
+meta = ("repo", "number", "author", "confidence", "label_source", "fetched_at", "is_spam")
+features = [c for c in dataset.columns if c not in meta]
 inps = dataset[features]
 targs = dataset["is_spam"]
 
@@ -20,12 +25,15 @@ clf = tree.DecisionTreeClassifier(
 clf.fit(inps, targs)
 clf.score(inps,targs)
 
+# gh-spam import data/cli-cli-sklearn.json
+export(clf, "data/cli-cli-sklearn.json")
+
 fig = plt.figure(figsize=(25,20))
 _ = tree.plot_tree(
         clf, 
         max_depth=2,
         filled=True,
         feature_names = features,
-        class_names = ["spam","not spam"]
+        class_names = ["not spam","spam"]
     )
 
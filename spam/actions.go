@@ -0,0 +1,205 @@
+package spam
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ActionData is passed to comment templates
+type ActionData struct {
+	Owner   string
+	Repo    string
+	Kind    string
+	ID      string
+	Number  int
+	Author  string
+	Score   float64
+	Verdict string
+}
+
+// supportsAction reports whether an action can be taken on a kind of item.
+// Comments can only be hidden, and only comments can be.
+func supportsAction(kind, action string) bool {
+	return (kind == KindComment) == (action == "hide")
+}
+
+// SkippedActions returns the configured actions that Apply skips for a kind
+// of item, because they can't be taken on it
+func (c Config) SkippedActions(kind string) []string {
+	skipped := []string{}
+	seen := map[string]bool{}
+	for _, action := range append(c.Actions.Spam, c.Actions.Uncertain...) {
+		if !supportsAction(kind, action) && !seen[action] {
+			seen[action] = true
+			skipped = append(skipped, action)
+		}
+	}
+	return skipped
+}
+
+// Apply takes the actions configured for the verdict on an issue. Actions
+// that can't be taken on its kind are skipped; see SkippedActions.
+func (c Config) Apply(data ActionData) error {
+	var actions, labels []string
+	var comment string
+	switch data.Verdict {
+	case "spam":
+		actions, labels, comment = c.Actions.Spam, c.Labels.Spam, c.Comments.Spam
+	case "uncertain":
+		actions, labels, comment = c.Actions.Uncertain, c.Labels.Uncertain, c.Comments.Uncertain
+	default:
+		return nil
+	}
+
+	// discussions aren't in the REST API
+	addLabels, addComment, closeItem := AddLabels, AddComment, CloseIssue
+	if data.Kind == KindDiscussion {
+		addLabels = func(owner, repo string, _ int, labels []string) error {
+			return addDiscussionLabels(owner, repo, data.ID, labels)
+		}
+		addComment = func(_, _ string, _ int, body string) error {
+			return addDiscussionComment(data.ID, body)
+		}
+		closeItem = func(_, _ string, _ int) error {
+			return closeDiscussion(data.ID)
+		}
+	}
+
+	for _, action := range actions {
+		if !supportsAction(data.Kind, action) {
+			continue
+		}
+		var err error
+		switch action {
+		case "hide":
+			err = MinimizeComment(data.ID)
+		case "label":
+			err = addLabels(data.Owner, data.Repo, data.Number, labels)
+		case "comment":
+			var body string
+			body, err = renderComment(comment, data)
+			if err == nil && body != "" {
+				err = addComment(data.Owner, data.Repo, data.Number, body)
+			}
+		case "close":
+			err = closeItem(data.Owner, data.Repo, data.Number)
+		}
+		if err != nil {
+			return fmt.Errorf("Error applying %s to #%d: %s", action, data.Number, err)
+		}
+	}
+	return nil
+}
+
+func renderComment(text string, data ActionData) (string, error) {
+	tmpl, err := template.New("comment").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Adds labels to an issue
+func AddLabels(owner, repo string, number int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	path := fmt.Sprintf("repos/%s/%s/issues/%d/labels", owner, repo, number)
+	return restPost(path, map[string]interface{}{"labels": labels})
+}
+
+// Posts a comment on an issue
+func AddComment(owner, repo string, number int, body string) error {
+	path := fmt.Sprintf("repos/%s/%s/issues/%d/comments", owner, repo, number)
+	return restPost(path, map[string]interface{}{"body": body})
+}
+
+// Closes an issue as not planned
+func CloseIssue(owner, repo string, number int) error {
+	client, err := restClient(false)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"state": "closed", "state_reason": "not_planned"})
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("repos/%s/%s/issues/%d", owner, repo, number)
+	return client.Patch(path, bytes.NewReader(body), nil)
+}
+
+func restPost(path string, payload interface{}) error {
+	client, err := restClient(false)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return client.Post(path, bytes.NewReader(body), nil)
+}
+
+func addDiscussionComment(id, body string) error {
+	query := `mutation AddComment($id: ID!, $body: String!) {
+  addDiscussionComment(input: {discussionId: $id, body: $body}) { clientMutationId }
+}`
+	return gqlMutate(query, map[string]interface{}{"id": id, "body": body})
+}
+
+func closeDiscussion(id string) error {
+	query := `mutation CloseDiscussion($id: ID!) {
+  closeDiscussion(input: {discussionId: $id, reason: OUTDATED}) { clientMutationId }
+}`
+	return gqlMutate(query, map[string]interface{}{"id": id})
+}
+
+func addDiscussionLabels(owner, repo, id string, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	client, err := gqlClient(false)
+	if err != nil {
+		return err
+	}
+
+	labelIDs := []string{}
+	for _, label := range labels {
+		query := `query GetLabel($owner: String!, $repo: String!, $name: String!) {
+  repository(owner: $owner, name: $repo) { label(name: $name) { id } }
+}`
+		resp := struct {
+			Repository struct{ Label *struct{ ID string } }
+		}{}
+		variables := map[string]interface{}{"owner": owner, "repo": repo, "name": label}
+		if err := client.Do(query, variables, &resp); err != nil {
+			return err
+		}
+		if resp.Repository.Label == nil {
+			return fmt.Errorf("label %q not found in %s/%s", label, owner, repo)
+		}
+		labelIDs = append(labelIDs, resp.Repository.Label.ID)
+	}
+
+	query := `mutation AddLabels($id: ID!, $labels: [ID!]!) {
+  addLabelsToLabelable(input: {labelableId: $id, labelIds: $labels}) { clientMutationId }
+}`
+	return gqlMutate(query, map[string]interface{}{"id": id, "labels": labelIDs})
+}
+
+func gqlMutate(query string, variables map[string]interface{}) error {
+	client, err := gqlClient(false)
+	if err != nil {
+		return err
+	}
+	resp := struct{}{}
+	return client.Do(query, variables, &resp)
+}
@@ -0,0 +1,86 @@
+package spam
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+)
+
+// Settings for the package's GitHub API clients
+var (
+	// CacheDir is where cacheable API responses are kept. Empty uses
+	// gh's default cache directory.
+	CacheDir string
+
+	// Offline answers requests from the cache however old the responses
+	// are. Requests that aren't cached fail.
+	Offline bool
+)
+
+// transport makes requests when not offline. Nil is http.DefaultTransport.
+// Tests replace it.
+var transport http.RoundTripper
+
+const (
+	// offlineTTL keeps cached responses usable in offline mode
+	offlineTTL = 10 * 365 * 24 * time.Hour
+
+	// liveTTL is how long lookups of items, and searches for recent ones,
+	// are cached online. They change as items are opened, edited and
+	// labeled, so they're cached only briefly, for offline mode to reuse.
+	liveTTL = 5 * time.Minute
+)
+
+// offlineTransport is reached only on cache misses in offline mode
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("offline: %s %s is not cached", req.Method, req.URL.Path)
+}
+
+func clientOptions(cache bool, timeout time.Duration) *api.ClientOptions {
+	opts := &api.ClientOptions{
+		EnableCache: cache || Offline,
+		CacheDir:    CacheDir,
+		Timeout:     timeout,
+		Transport:   transport,
+	}
+	if Offline {
+		opts.CacheTTL = offlineTTL
+		opts.Transport = offlineTransport{}
+	}
+	return opts
+}
+
+// gqlClient makes a GraphQL client. Responses are cached if cache is set.
+func gqlClient(cache bool) (api.GQLClient, error) {
+	return gh.GQLClient(clientOptions(cache, 0))
+}
+
+// restClient makes a REST client. Responses are cached if cache is set.
+func restClient(cache bool) (api.RESTClient, error) {
+	return gh.RESTClient(clientOptions(cache, 0))
+}
+
+// liveClientOptions caches responses for liveTTL, or as long as other
+// responses in offline mode
+func liveClientOptions() *api.ClientOptions {
+	opts := clientOptions(true, 0)
+	if !Offline {
+		opts.CacheTTL = liveTTL
+	}
+	return opts
+}
+
+// liveGQLClient makes a GraphQL client for lookups of items that change
+func liveGQLClient() (api.GQLClient, error) {
+	return gh.GQLClient(liveClientOptions())
+}
+
+// liveRESTClient makes a REST client for lookups of items that change
+func liveRESTClient() (api.RESTClient, error) {
+	return gh.RESTClient(liveClientOptions())
+}
@@ -0,0 +1,89 @@
+package spam
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGitHub answers GraphQL item lookups and searches with one issue
+type fakeGitHub struct {
+	requests int
+}
+
+func (f *fakeGitHub) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	issue := `{"__typename": "Issue", "number": 4894, "title": "Buy now", "createdAt": "2022-01-10T12:00:00Z", "author": {"__typename": "User", "login": "spammer"}}`
+	data := `{"data": {"repository": {"item": ` + issue + `}}}`
+	if strings.Contains(string(body), "search(") {
+		data = `{"data": {"search": {"pageInfo": {"hasNextPage": false}, "nodes": [` + issue + `]}}}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(data)),
+		Request:    req,
+	}, nil
+}
+
+func TestOffline(t *testing.T) {
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	t.Setenv("GH_TOKEN", "token")
+	fake := &fakeGitHub{}
+	defer func(dir string, rt http.RoundTripper) {
+		CacheDir, transport, Offline = dir, rt, false
+	}(CacheDir, transport)
+	CacheDir, transport = t.TempDir(), fake
+
+	// looking items up online caches them, briefly, for offline mode
+	morning := time.Date(2022, 1, 10, 9, 0, 0, 0, time.UTC)
+	if _, err := GetItemByNumber(KindIssue, "cli", "cli", 4894); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetRecentItems(KindIssue, "cli", "cli", morning, 10); err != nil {
+		t.Fatal(err)
+	}
+	if fake.requests != 2 {
+		t.Fatalf("got %d requests, want 2", fake.requests)
+	}
+
+	Offline = true
+	issue, err := GetItemByNumber(KindIssue, "cli", "cli", 4894)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Title != "Buy now" || issue.Author.Login != "spammer" {
+		t.Errorf("got %+v from the cache", issue)
+	}
+	recent, err := GetRecentItems(KindIssue, "cli", "cli", morning, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 {
+		t.Errorf("got %d recent issues from the cache, want 1", len(recent))
+	}
+
+	if _, err := GetItemByNumber(KindIssue, "cli", "cli", 1); err == nil || !strings.Contains(err.Error(), "not cached") {
+		t.Errorf("got %v, want a cache miss", err)
+	}
+	if fake.requests != 2 {
+		t.Errorf("got %d requests, want none offline", fake.requests)
+	}
+}
+
+func TestLiveClientOptions(t *testing.T) {
+	defer func() { Offline = false }()
+	if opts := liveClientOptions(); !opts.EnableCache || opts.CacheTTL != liveTTL {
+		t.Errorf("got cache %v for %s online, want %s", opts.EnableCache, opts.CacheTTL, liveTTL)
+	}
+	Offline = true
+	if opts := liveClientOptions(); opts.CacheTTL != offlineTTL {
+		t.Errorf("got cache TTL %s offline, want %s", opts.CacheTTL, offlineTTL)
+	}
+}
@@ -0,0 +1,294 @@
+package spam
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/pkg/api"
+)
+
+// KindComment is an issue or pull request comment. Comments are Issues
+// whose Number is the comment's database ID and whose Parent is set.
+const KindComment = "comment"
+
+// minimized comments with these reasons are labeled spam
+var spamReasons = map[string]bool{
+	"spam":  true,
+	"abuse": true,
+}
+
+const commentFields = `
+          id
+          databaseId
+          url
+          author { __typename login }
+          body
+          authorAssociation
+          createdAt
+          isMinimized
+          minimizedReason
+          userContentEdits { totalCount }`
+
+const parentFields = `
+        number
+        title
+        body
+        createdAt`
+
+// commentNode is a comment as returned by GraphQL queries
+type commentNode struct {
+	ID                string
+	DatabaseID        int
+	URL               string
+	Author            Actor
+	Body              string
+	AuthorAssociation string
+	CreatedAt         string
+	IsMinimized       bool
+	MinimizedReason   string
+	UserContentEdits  struct{ TotalCount int }
+}
+
+// parentNode is an issue or pull request with its comments
+type parentNode struct {
+	Typename  string `json:"__typename"`
+	Number    int
+	Title     string
+	Body      string
+	CreatedAt string
+	Comments  struct{ Nodes []commentNode }
+}
+
+func (n commentNode) toIssue(owner, repo string, parent *Issue) Issue {
+	issue := Issue{
+		ID:                n.ID,
+		Kind:              KindComment,
+		Number:            n.DatabaseID,
+		URL:               n.URL,
+		Body:              n.Body,
+		Author:            n.Author,
+		AuthorAssociation: n.AuthorAssociation,
+		CreatedAt:         n.CreatedAt,
+		Edits:             n.UserContentEdits.TotalCount,
+		IsSpam:            n.IsMinimized && spamReasons[strings.ToLower(n.MinimizedReason)],
+		Parent:            parent,
+	}
+	issue.Repository.NameWithOwner = owner + "/" + repo
+	return issue
+}
+
+func (n parentNode) toIssue() *Issue {
+	parent := &Issue{
+		Kind:      KindIssue,
+		Number:    n.Number,
+		Title:     n.Title,
+		Body:      n.Body,
+		CreatedAt: n.CreatedAt,
+	}
+	if n.Typename == "PullRequest" {
+		parent.Kind = KindPR
+	}
+	return parent
+}
+
+// Gets comments on a repo's recently updated issues and pull requests,
+// newest issues first. Comments minimized as spam or abuse are labeled spam.
+func GetComments(owner, repo string, limit int) ([]Issue, error) {
+	q := fmt.Sprintf("repo:%s/%s comments:>0 sort:updated-desc", owner, repo)
+	client, err := gqlClient(true)
+	if err != nil {
+		return nil, err
+	}
+	return commentSearchQuery(client, owner, repo, q, time.Time{}, limit, nil)
+}
+
+// Gets comments created since a time
+func GetRecentComments(owner, repo string, since time.Time, limit int) ([]Issue, error) {
+	q := fmt.Sprintf("repo:%s/%s comments:>0 updated:>=%s sort:updated-desc",
+		owner, repo, since.UTC().Format(time.RFC3339))
+	client, err := liveGQLClient()
+	if err != nil {
+		return nil, err
+	}
+	return commentSearchQuery(client, owner, repo, q, since, limit, nil)
+}
+
+// commentSearchQuery gets up to limit comments on the search results, that
+// were created since a time and that keep accepts if it isn't nil
+func commentSearchQuery(client api.GQLClient, owner, repo, query string, since time.Time, limit int, keep func(Issue) bool) ([]Issue, error) {
+	gqlQuery := `query SearchComments($query: String!, $after: String) {
+search(query: $query, after: $after, type: ISSUE, first: 50) {
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    nodes {
+      __typename
+      ... on Issue {` + parentFields + `
+        comments(last: 100) { nodes {` + commentFields + `
+        } }
+      }
+      ... on PullRequest {` + parentFields + `
+        comments(last: 100) { nodes {` + commentFields + `
+        } }
+      }
+    }
+  }
+}`
+
+	comments := []Issue{}
+	variables := map[string]interface{}{"query": query}
+	for {
+		resp := struct {
+			Search struct {
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   string
+				}
+				Nodes []parentNode
+			}
+		}{}
+		if err := client.Do(gqlQuery, variables, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, node := range resp.Search.Nodes {
+			parent := node.toIssue()
+			for _, c := range node.Comments.Nodes {
+				comment := c.toIssue(owner, repo, parent)
+				created, _ := time.Parse(time.RFC3339, comment.CreatedAt)
+				if comment.Body == "" || comment.Author.Login == "" || created.Before(since) {
+					continue
+				}
+				if keep != nil && !keep(comment) {
+					continue
+				}
+				comments = append(comments, comment)
+				if len(comments) >= limit {
+					return comments, nil
+				}
+			}
+		}
+
+		if !resp.Search.PageInfo.HasNextPage {
+			return comments, nil
+		}
+		variables["after"] = resp.Search.PageInfo.EndCursor
+	}
+}
+
+var commentURLRE = regexp.MustCompile(`^/([^/]+)/([^/]+)/(?:issues|pull)/(\d+)$`)
+
+// ParseCommentURL gets the repo and comment ID from a URL like
+// https://github.com/OWNER/REPO/issues/1#issuecomment-123
+func ParseCommentURL(commentURL string) (owner, repo string, id int, err error) {
+	u, err := url.Parse(commentURL)
+	if err != nil {
+		return "", "", 0, err
+	}
+	m := commentURLRE.FindStringSubmatch(u.Path)
+	if m == nil || !strings.HasPrefix(u.Fragment, "issuecomment-") {
+		return "", "", 0, fmt.Errorf("Invalid comment URL %s", commentURL)
+	}
+	id, err = strconv.Atoi(strings.TrimPrefix(u.Fragment, "issuecomment-"))
+	if err != nil {
+		return "", "", 0, fmt.Errorf("Invalid comment URL %s", commentURL)
+	}
+	return m[1], m[2], id, nil
+}
+
+// Gets an issue or pull request comment with its parent from its URL
+func GetCommentByURL(commentURL string) (Issue, error) {
+	owner, repo, id, err := ParseCommentURL(commentURL)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	rest, err := liveRESTClient()
+	if err != nil {
+		return Issue{}, err
+	}
+	ref := struct {
+		NodeID string `json:"node_id"`
+	}{}
+	if err := rest.Get(fmt.Sprintf("repos/%s/%s/issues/comments/%d", owner, repo, id), &ref); err != nil {
+		return Issue{}, err
+	}
+
+	query := `query GetComment($id: ID!) {
+  node(id: $id) {
+    ... on IssueComment {` + commentFields + `
+      issue {
+        __typename` + parentFields + `
+      }
+      pullRequest {
+        __typename` + parentFields + `
+      }
+    }
+  }
+}`
+	resp := struct {
+		Node struct {
+			commentNode
+			Issue       *parentNode
+			PullRequest *parentNode
+		}
+	}{}
+	client, err := liveGQLClient()
+	if err != nil {
+		return Issue{}, err
+	}
+	if err := client.Do(query, map[string]interface{}{"id": ref.NodeID}, &resp); err != nil {
+		return Issue{}, err
+	}
+
+	parent := resp.Node.Issue
+	if resp.Node.PullRequest != nil {
+		parent = resp.Node.PullRequest
+	}
+	if parent == nil {
+		return Issue{}, fmt.Errorf("comment %s not found", commentURL)
+	}
+	return resp.Node.commentNode.toIssue(owner, repo, parent.toIssue()), nil
+}
+
+// Hides a comment as spam
+func MinimizeComment(id string) error {
+	query := `mutation Minimize($id: ID!) {
+  minimizeComment(input: {subjectId: $id, classifier: SPAM}) { clientMutationId }
+}`
+	return gqlMutate(query, map[string]interface{}{"id": id})
+}
+
+var linkRE = regexp.MustCompile(`https?://\S+`)
+
+// CountLinks counts the URLs in text
+func CountLinks(text string) int {
+	return len(linkRE.FindAllString(text, -1))
+}
+
+// WordSimilarity is the Jaccard similarity of the lowercase word sets of a and b
+func WordSimilarity(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := map[string]bool{}
+		for _, w := range strings.Fields(strings.ToLower(s)) {
+			set[w] = true
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range wa {
+		if wb[w] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(wa)+len(wb)-inter)
+}
@@ -0,0 +1,103 @@
+package spam
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommentURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		owner string
+		repo  string
+		id    int
+		err   bool
+	}{
+		{url: "https://github.com/cli/cli/issues/1#issuecomment-123", owner: "cli", repo: "cli", id: 123},
+		{url: "https://github.com/cli/cli/pull/2#issuecomment-456", owner: "cli", repo: "cli", id: 456},
+		{url: "https://github.com/cli/cli/issues/1", err: true},
+		{url: "https://github.com/cli/cli/issues/1#issuecomment-x", err: true},
+		{url: "https://github.com/cli/cli/discussions/1#discussioncomment-1", err: true},
+	}
+	for _, tt := range tests {
+		owner, repo, id, err := ParseCommentURL(tt.url)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.url, err)
+			continue
+		}
+		if owner != tt.owner || repo != tt.repo || id != tt.id {
+			t.Errorf("%s: got %s/%s %d, want %s/%s %d", tt.url, owner, repo, id, tt.owner, tt.repo, tt.id)
+		}
+	}
+}
+
+func TestCommentToIssue(t *testing.T) {
+	n := commentNode{
+		ID:              "IC_1",
+		DatabaseID:      123,
+		Body:            "Buy now",
+		IsMinimized:     true,
+		MinimizedReason: "SPAM",
+	}
+	parent := parentNode{Typename: "PullRequest", Number: 7, Title: "Fix crash"}.toIssue()
+	issue := n.toIssue("cli", "cli", parent)
+	if issue.Kind != KindComment || issue.Number != 123 || !issue.IsSpam || issue.Repository.NameWithOwner != "cli/cli" {
+		t.Errorf("got %+v, want spam comment 123 in cli/cli", issue)
+	}
+	if issue.Parent.Kind != KindPR || issue.Parent.Number != 7 {
+		t.Errorf("got parent %+v, want PR #7", issue.Parent)
+	}
+
+	n.MinimizedReason = "OUTDATED"
+	if n.toIssue("cli", "cli", parent).IsSpam {
+		t.Error("expected a comment minimized as outdated not to be spam")
+	}
+}
+
+func TestSkippedActions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Actions.Spam = []string{"label", "hide", "close"}
+	cfg.Actions.Uncertain = []string{"label"}
+
+	if got, want := cfg.SkippedActions(KindComment), []string{"label", "close"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("comments: got %v, want %v", got, want)
+	}
+	if got, want := cfg.SkippedActions(KindIssue), []string{"hide"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("issues: got %v, want %v", got, want)
+	}
+}
+
+func TestRenderComment(t *testing.T) {
+	body, err := renderComment("  Closing as spam. @{{.Author}} \n", ActionData{Author: "spammer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "Closing as spam. @spammer" {
+		t.Errorf("got %q", body)
+	}
+	if _, err := renderComment("{{.Missing}}", ActionData{}); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestCommentQuota(t *testing.T) {
+	q := newCommentQuota(10, 0.3)
+	kept := 0
+	for i := 0; i < 20; i++ {
+		if q.keep(Issue{Kind: KindComment, Number: i}) {
+			kept++
+		}
+	}
+	if kept != 7 {
+		t.Errorf("got %d non-spam comments, want 7", kept)
+	}
+	if !q.keep(Issue{Kind: KindComment, IsSpam: true}) {
+		t.Error("expected spam comments to be kept past the non-spam share")
+	}
+}
@@ -0,0 +1,217 @@
+package spam
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigPath is where a repo keeps its classification policy
+const ConfigPath = ".github/gh-spam.yml"
+
+// Config is the per-repo classification policy
+type Config struct {
+	// Model is the path of the trained model
+	Model string `yaml:"model"`
+
+	// Thresholds on the spam probability. Issues scoring at least Spam
+	// are spam, at least Uncertain are uncertain, and the rest are not spam
+	Thresholds struct {
+		Spam      float64 `yaml:"spam"`
+		Uncertain float64 `yaml:"uncertain"`
+		// SpamSet is whether the policy sets Spam rather than taking the
+		// default, so a model's own threshold doesn't override it
+		SpamSet bool `yaml:"-"`
+	} `yaml:"thresholds"`
+
+	// Labels to add to spam and uncertain issues
+	Labels struct {
+		Spam      []string `yaml:"spam"`
+		Uncertain []string `yaml:"uncertain"`
+	} `yaml:"labels"`
+
+	// Allow lists users, teams (as org/team-slug) and orgs that never post spam
+	Allow struct {
+		Users []string `yaml:"users"`
+		Teams []string `yaml:"teams"`
+		Orgs  []string `yaml:"orgs"`
+	} `yaml:"allow"`
+
+	// Block lists users that always post spam
+	Block struct {
+		Users []string `yaml:"users"`
+	} `yaml:"block"`
+
+	// Keywords in the title or body that mark an issue as spam
+	Keywords []string `yaml:"keywords"`
+
+	// Rules run after the allow, block and keyword lists and the built-in
+	// rules, before the model
+	Rules []Rule `yaml:"rules"`
+
+	// DisableRules names built-in rules to turn off, e.g. contributor
+	DisableRules []string `yaml:"disable_rules"`
+
+	// Actions to take on spam and uncertain issues: label, comment or close.
+	// Comments can only be hidden, with the hide action; Apply skips
+	// actions that can't be taken on an item's kind.
+	Actions struct {
+		Spam      []string `yaml:"spam"`
+		Uncertain []string `yaml:"uncertain"`
+	} `yaml:"actions"`
+
+	// Comments are text/template strings posted by the comment action
+	Comments struct {
+		Spam      string `yaml:"spam"`
+		Uncertain string `yaml:"uncertain"`
+	} `yaml:"comments"`
+
+	// Drift bounds the logged predictions checked by the drift command.
+	// Crossing a bound recommends retraining, or retrains if Retrain is set.
+	Drift struct {
+		MaxPSI         float64 `yaml:"max_psi"`
+		MinPrecision   float64 `yaml:"min_precision"`
+		MinPredictions int     `yaml:"min_predictions"`
+		Retrain        bool    `yaml:"retrain"`
+	} `yaml:"drift"`
+}
+
+var validActions = map[string]bool{
+	"label":   true,
+	"comment": true,
+	"close":   true,
+	"hide":    true,
+}
+
+// DefaultConfig is the policy used when a repo has no config file
+func DefaultConfig() Config {
+	cfg := Config{}
+	cfg.Thresholds.Spam = 0.5
+	cfg.Thresholds.Uncertain = 0.5
+	cfg.Labels.Spam = []string{"spam"}
+	cfg.Actions.Spam = []string{"label"}
+	cfg.Drift.MaxPSI = 0.25
+	cfg.Drift.MinPrecision = 0.9
+	cfg.Drift.MinPredictions = 30
+	return cfg
+}
+
+// ParseConfig reads a YAML policy on top of the default config
+func ParseConfig(data []byte) (Config, error) {
+	cfg := DefaultConfig()
+	// an unset uncertain threshold defaults to at most the spam threshold
+	defaultSpam, defaultUncertain := cfg.Thresholds.Spam, cfg.Thresholds.Uncertain
+	cfg.Thresholds.Spam, cfg.Thresholds.Uncertain = -1, -1
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("Invalid config: %s", err)
+	}
+	cfg.Thresholds.SpamSet = cfg.Thresholds.Spam != -1
+	if !cfg.Thresholds.SpamSet {
+		cfg.Thresholds.Spam = defaultSpam
+	}
+	if cfg.Thresholds.Uncertain == -1 {
+		cfg.Thresholds.Uncertain = math.Min(defaultUncertain, cfg.Thresholds.Spam)
+	}
+
+	if cfg.Thresholds.Spam < 0 || cfg.Thresholds.Spam > 1 {
+		return cfg, fmt.Errorf("Invalid config: spam threshold must be between 0 and 1")
+	}
+	if cfg.Thresholds.Uncertain < 0 {
+		return cfg, fmt.Errorf("Invalid config: uncertain threshold must be between 0 and the spam threshold")
+	}
+	if cfg.Thresholds.Uncertain > cfg.Thresholds.Spam {
+		return cfg, fmt.Errorf("Invalid config: uncertain threshold is above spam threshold")
+	}
+	for _, action := range append(cfg.Actions.Spam, cfg.Actions.Uncertain...) {
+		if !validActions[action] {
+			return cfg, fmt.Errorf("Invalid config: unknown action %q", action)
+		}
+	}
+	return cfg, nil
+}
+
+// LoadConfig reads a policy from a local file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// GetRepoConfig reads the policy from the repo's default branch,
+// falling back to the default config if there is none
+func GetRepoConfig(owner, repo string) (Config, error) {
+	data, err := GetRepoFile(owner, repo, ConfigPath)
+	if err != nil {
+		if isNotFound(err) {
+			return DefaultConfig(), nil
+		}
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// GetRepoFile reads a file from the repo's default branch
+func GetRepoFile(owner, repo, path string) ([]byte, error) {
+	client, err := restClient(true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := struct {
+		Content  string
+		Encoding string
+	}{}
+	if err := client.Get(fmt.Sprintf("repos/%s/%s/contents/%s", owner, repo, path), &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(resp.Content, "\n", ""))
+}
+
+// listRepoDir lists the paths of the files in a directory of the repo's
+// default branch. A missing directory is empty.
+func listRepoDir(owner, repo, dir string) ([]string, error) {
+	client, err := restClient(true)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []struct {
+		Path string
+		Type string
+	}{}
+	if err := client.Get(fmt.Sprintf("repos/%s/%s/contents/%s", owner, repo, dir), &entries); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, entry := range entries {
+		if entry.Type == "file" {
+			paths = append(paths, entry.Path)
+		}
+	}
+	return paths, nil
+}
+
+// Verdict returns "spam", "uncertain" or "not spam" for a spam probability
+func (c Config) Verdict(score float64) string {
+	if score >= c.Thresholds.Spam {
+		return "spam"
+	}
+	if score >= c.Thresholds.Uncertain {
+		return "uncertain"
+	}
+	return "not spam"
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "HTTP 404")
+}
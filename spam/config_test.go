@@ -0,0 +1,54 @@
+package spam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		yaml            string
+		spam, uncertain float64
+		wantErr         bool
+	}{
+		{name: "default", yaml: "", spam: 0.5, uncertain: 0.5},
+		{name: "low spam threshold", yaml: "thresholds: {spam: 0.3}", spam: 0.3, uncertain: 0.3},
+		{name: "high spam threshold", yaml: "thresholds: {spam: 0.9}", spam: 0.9, uncertain: 0.5},
+		{name: "both", yaml: "thresholds: {spam: 0.9, uncertain: 0.6}", spam: 0.9, uncertain: 0.6},
+		{name: "zero uncertain", yaml: "thresholds: {uncertain: 0}", spam: 0.5, uncertain: 0},
+		{name: "uncertain above spam", yaml: "thresholds: {spam: 0.6, uncertain: 0.7}", wantErr: true},
+		{name: "negative uncertain", yaml: "thresholds: {uncertain: -0.1}", wantErr: true},
+		{name: "spam above 1", yaml: "thresholds: {spam: 1.5}", wantErr: true},
+		{name: "unknown action", yaml: "actions: {spam: [delete]}", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := ParseConfig([]byte(tt.yaml))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if cfg.Thresholds.Spam != tt.spam || cfg.Thresholds.Uncertain != tt.uncertain {
+			t.Errorf("%s: got thresholds %v and %v, want %v and %v", tt.name, cfg.Thresholds.Spam, cfg.Thresholds.Uncertain, tt.spam, tt.uncertain)
+		}
+		if want := strings.Contains(tt.yaml, "spam:"); cfg.Thresholds.SpamSet != want {
+			t.Errorf("%s: got SpamSet %v, want %v", tt.name, cfg.Thresholds.SpamSet, want)
+		}
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Thresholds.Spam, cfg.Thresholds.Uncertain = 0.8, 0.4
+	for score, want := range map[float64]string{0.9: "spam", 0.8: "spam", 0.5: "uncertain", 0.1: "not spam"} {
+		if got := cfg.Verdict(score); got != want {
+			t.Errorf("score %v: got %s, want %s", score, got, want)
+		}
+	}
+}
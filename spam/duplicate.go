@@ -1,6 +1,97 @@
 package spam
 
-import "github.com/ktr0731/go-fuzzyfinder/scoring"
+import (
+	"strings"
+
+	"github.com/ktr0731/go-fuzzyfinder/scoring"
+)
+
+// Template is an issue template preprocessed for similarity scoring
+type Template struct {
+	Body     string
+	shingles map[uint64]struct{}
+	headings []string
+	sections []section
+}
+
+// NewTemplates preprocesses template bodies for similarity scoring
+func NewTemplates(bodies []string) []Template {
+	templates := make([]Template, len(bodies))
+	for i, body := range bodies {
+		templates[i] = Template{
+			Body:     body,
+			shingles: wordShingles(body, shingleSize),
+			headings: headings(body),
+			sections: templateSections(body),
+		}
+	}
+	return templates
+}
+
+// Similarity is between 0 and 1. For templates with headings it is the
+// fraction of headings retained in the body, otherwise it is the Jaccard
+// similarity of the word shingles.
+func (t Template) Similarity(body string) float64 {
+	if len(t.headings) > 0 {
+		found := map[string]bool{}
+		for _, h := range headings(body) {
+			found[h] = true
+		}
+		kept := 0
+		for _, h := range t.headings {
+			if found[h] {
+				kept++
+			}
+		}
+		return float64(kept) / float64(len(t.headings))
+	}
+	return jaccard(t.shingles, wordShingles(body, shingleSize))
+}
+
+// MaxTemplateSim is the highest similarity between the body and the templates
+func MaxTemplateSim(body string, templates []Template) float64 {
+	if body == "" {
+		return 0
+	}
+
+	max := 0.0
+	for _, t := range templates {
+		if sim := t.Similarity(body); sim > max {
+			max = sim
+		}
+	}
+	return max
+}
+
+// headings returns the normalized markdown headings and bold lines of text
+func headings(text string) []string {
+	hs := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		isHeading := strings.HasPrefix(line, "#") ||
+			(len(line) > 4 && strings.HasPrefix(line, "**") && strings.HasSuffix(line, "**"))
+		if !isHeading {
+			continue
+		}
+		if h := normalizeHeading(line); h != "" {
+			hs = append(hs, h)
+		}
+	}
+	return hs
+}
+
+func jaccard(a, b map[uint64]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for sh := range a {
+		if _, ok := b[sh]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
 
 func simScores(query string, docs []string) []int {
 	sims := []int{}
@@ -18,6 +109,8 @@ func simScores(query string, docs []string) []int {
 	return sims
 }
 
+// Deprecated: MaxSimScore is unnormalized and quadratic in the text length,
+// use MaxTemplateSim.
 func MaxSimScore(query string, docs []string) int {
 	if query == "" {
 		return 0
@@ -0,0 +1,52 @@
+package spam
+
+import (
+	"strings"
+	"testing"
+)
+
+var benchTemplates = []string{
+	"### Describe the bug\n\nA clear and concise description of what the bug is.\n\n### Steps to reproduce the behavior\n\n1. Type this '...'\n2. View the output\n\n### Expected vs actual behavior\n\nA clear and concise description of what you expected to happen.\n\n### Logs\n\nPaste the activity from your command line.",
+	"### Describe the feature or problem you'd like to solve\n\nA clear and concise description of what the feature or problem is.\n\n### Proposed solution\n\nHow will it benefit CLI and its users?\n\n### Additional context\n\nAdd any other context like screenshots or mockups.",
+	"Please fill out the following information about your issue so we can help you as quickly as possible. Include the version you are running and the operating system.",
+}
+
+func largeBody(n int) string {
+	var b strings.Builder
+	b.WriteString("### Describe the bug\n\n")
+	for b.Len() < n {
+		b.WriteString("running gh pr create in a repo without a remote prints a stack trace and exits. ")
+	}
+	b.WriteString("\n\n### Logs\n\nnone")
+	return b.String()
+}
+
+func TestMaxTemplateSim(t *testing.T) {
+	templates := NewTemplates(benchTemplates)
+
+	if sim := MaxTemplateSim(benchTemplates[0], templates); sim != 1 {
+		t.Errorf("template against itself: got %.3f, want 1", sim)
+	}
+	if sim := MaxTemplateSim("buy followers at example.com", templates); sim != 0 {
+		t.Errorf("unrelated body: got %.3f, want 0", sim)
+	}
+	if sim := MaxTemplateSim(largeBody(2000), templates); sim <= 0 || sim >= 1 {
+		t.Errorf("partially filled template: got %.3f, want between 0 and 1", sim)
+	}
+}
+
+func BenchmarkMaxSimScore(b *testing.B) {
+	body := largeBody(8000)
+	for i := 0; i < b.N; i++ {
+		MaxSimScore(body, benchTemplates)
+	}
+}
+
+func BenchmarkMaxTemplateSim(b *testing.B) {
+	body := largeBody(8000)
+	templates := NewTemplates(benchTemplates)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MaxTemplateSim(body, templates)
+	}
+}
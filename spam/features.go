@@ -9,6 +9,15 @@ import (
 	"github.com/cheggaaa/pb/v3"
 )
 
+var categoryToClass = map[string]int{
+	"Announcements": 1,
+	"General":       2,
+	"Ideas":         3,
+	"Polls":         4,
+	"Q&A":           5,
+	"Show and tell": 6,
+}
+
 var assocToClass = map[string]int{
 	"NONE":                   0,
 	"FIRST_TIMER":            0,
@@ -22,8 +31,19 @@ var assocToClass = map[string]int{
 type MakeOpts struct {
 	Owner   string
 	Repo    string
+	Kind    string
 	Limit   int
 	Verbose bool
+
+	// SpamRatio is the fraction of Limit reserved for spam. The rest is
+	// filled with non-spam, and spam takes up any that's left over.
+	SpamRatio float64
+
+	// Rules with a "not spam" verdict override the spam label
+	Rules *RuleSet
+
+	// Templates to match issues against. If nil they are fetched from the repo.
+	Templates []Template
 }
 
 func MakeDataset(opts MakeOpts) ([]Features, error) {
@@ -31,38 +51,61 @@ func MakeDataset(opts MakeOpts) ([]Features, error) {
 		log.Printf("Downloading issues for %s/%s\n", opts.Owner, opts.Repo)
 	}
 
-	issues, err := downloadIssues(opts.Owner, opts.Repo, opts.Limit)
+	issues, err := downloadIssues(opts.Kind, opts.Owner, opts.Repo, opts.Limit, opts.SpamRatio)
 	if err != nil {
 		return nil, err
 	}
 
-	authors := map[string]User{}
-	feats := []Features{}
-
 	// fetch issue templates for matching
-	templates, err := GetTemplates(opts.Owner, opts.Repo)
+	templates := opts.Templates
+	if templates == nil {
+		templates, err = GetTemplatesFor(opts.Kind, opts.Owner, opts.Repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// index the repo's items before each issue to find waves of
+	// near-duplicates, as they are found when classifying
+	times := []time.Time{}
+	for _, issue := range issues {
+		if created, err := time.Parse(time.RFC3339, issue.CreatedAt); err == nil {
+			times = append(times, created)
+		}
+	}
+	dups, err := IndexDupWindows(opts.Kind, opts.Owner, opts.Repo, times)
 	if err != nil {
 		return nil, err
 	}
 
 	if opts.Verbose {
 		log.Printf("%d templates\n", len(templates))
+		log.Printf("%d duplicate clusters\n", len(dups.Clusters()))
 		log.Println("Processing Issues")
 	}
 
-	// get the issue author's stats to compute dataset features
+	// get the issue author's stats and history to compute dataset features
+	ext := NewExtractor(opts.Owner, opts.Repo, templates, dups)
+	feats := []Features{}
 	bar := pb.StartNew(len(issues))
 	for _, issue := range issues {
-		username := issue.Author.Login
-		author, ok := authors[username]
-		if !ok {
-			author, err = GetUserStats(username)
+		feat, err := ext.Extract(issue)
+		if err != nil {
+			if opts.Verbose {
+				log.Println(err)
+			}
+			continue
+		}
+		if opts.Rules != nil {
+			res, err := opts.Rules.Evaluate(issue)
 			if err != nil {
-				continue
+				bar.Finish()
+				return nil, err
+			}
+			if res.Verdict == "not spam" {
+				feat.IsSpam = 0
 			}
-			authors[username] = author
 		}
-		feat := ExtractFeatures(issue, author, templates)
 		feats = append(feats, feat)
 		bar.Increment()
 	}
@@ -72,6 +115,14 @@ func MakeDataset(opts MakeOpts) ([]Features, error) {
 }
 
 type Features struct {
+	// Kind of item the features are for, which decides the model's columns
+	Kind string
+
+	// Number of the item the features are for, and the login of its
+	// author. They are not features.
+	Number int
+	Author string
+
 	// A class label for author's association to the repo
 	Association int
 
@@ -86,65 +137,315 @@ type Features struct {
 	// when the issue was posted
 	AccountAge int
 
+	// AccountAgeMinutes is AccountAge in minutes, to tell apart brand-new accounts
+	AccountAgeMinutes int
+
+	// The UTC hour and day of the week (0 is Sunday) the issue was posted
+	PostHour    int
+	PostWeekday int
+
+	// PriorGap is the minutes since the author's previous issue or PR
+	// in the repo, or -1 if there was none
+	PriorGap int
+
+	// Edits is the number of times the issue was edited after it was posted
+	Edits int
+
 	// Number of chars in the Issue content
 	TitleLen int
 	BodyLen  int
 
-	// The max similarity score between the issue and the repo's issue templates
-	TemplateScore int
+	// The max similarity between the issue and the repo's issue templates
+	// and forms, from 0 to 1
+	TemplateSim float64
+
+	// How the issue fills in the most similar template: the number of its
+	// headings removed, required form fields left empty, and sections left
+	// with the placeholder text
+	HeadingsRemoved int
+	EmptyRequired   int
+	Placeholders    int
 
 	Followers int
 	Following int
 
+	// Profile fields the author filled in, 1 if set else 0
+	HasName     int
+	HasBio      int
+	HasCompany  int
+	HasLocation int
+	HasWebsite  int
+
+	// 1 if the author is a site admin, a bot, or in any public org, else 0
+	SiteAdmin int
+	IsBot     int
+	OrgMember int
+
+	PublicRepos  int
+	PublicGists  int
+	StarredRepos int
+
+	// Days with contributions in the last year, and the longest run of them
+	ActiveDays    int
+	LongestStreak int
+
+	// DupClusterSize is 1 plus the number of near-duplicates opened in the
+	// repo in the DupWindow before this one
+	DupClusterSize int
+
+	// The author's issues and PRs in the repo before this one,
+	// how many were closed within a day without comments,
+	// and how many of the PRs were merged
+	PriorIssues int
+	PriorPRs    int
+	QuickClosed int
+	MergedPRs   int
+
+	// CrossRepoIssues is the number of other repos the author
+	// opened issues in within an hour of this one
+	CrossRepoIssues int
+
+	// Pull request changes: 1 if only docs changed, and the number
+	// of commits by the PR's author
+	ChangedFiles  int
+	Additions     int
+	Deletions     int
+	DocsOnly      int
+	AuthorCommits int
+
+	// Discussion category as a class label, and 1 if answered
+	Category int
+	Answered int
+
+	// Comment features: the number of links, the word similarity to
+	// the parent issue or PR, and the minutes since the parent was posted
+	CommentLinks int
+	ParentSim    float64
+	ParentAge    int
+
 	// IsSpam is 1 if issue was spam, else 0
 	IsSpam int
 }
 
 // ExtractFeatures gets numeric features from issue for classification
-func ExtractFeatures(issue Issue, author User, templates []string) Features {
+func ExtractFeatures(issue Issue, author User, templates []Template) Features {
 	issueCreated, _ := time.Parse(time.RFC3339, issue.CreatedAt)
 	acctCreated, _ := time.Parse(time.RFC3339, author.CreatedAt)
-	acctAge := int(issueCreated.Sub(acctCreated).Hours() / 24)
+	acctAge := issueCreated.Sub(acctCreated)
 
-	simScore := MaxSimScore(issue.Body, templates)
+	match := MatchTemplate(issue.Body, templates)
 
 	feats := Features{
-		Association:   assocToClass[issue.AuthorAssociation],
-		Following:     author.Following,
-		Followers:     author.Followers,
-		AccountAge:    acctAge,
-		Contributions: author.TotalContributions,
-		AuthorRepos:   author.ReposContributed,
-		TitleLen:      len(issue.Title),
-		BodyLen:       len(issue.Body),
-		TemplateScore: simScore,
-	}
-
-	// assume contributors never post spam
-	if issue.IsSpam && feats.Association < 3 {
+		Kind:              issue.Kind,
+		Number:            issue.Number,
+		Author:            issue.Author.Login,
+		Association:       assocToClass[issue.AuthorAssociation],
+		Following:         author.Following,
+		Followers:         author.Followers,
+		AccountAge:        int(acctAge.Hours() / 24),
+		AccountAgeMinutes: int(acctAge.Minutes()),
+		PostHour:          issueCreated.UTC().Hour(),
+		PostWeekday:       int(issueCreated.UTC().Weekday()),
+		Edits:             issue.Edits,
+		Contributions:     author.TotalContributions,
+		AuthorRepos:       author.ReposContributed,
+		TitleLen:          len(issue.Title),
+		BodyLen:           len(issue.Body),
+		TemplateSim:       match.Sim,
+		HeadingsRemoved:   match.HeadingsRemoved,
+		EmptyRequired:     match.EmptyRequired,
+		Placeholders:      match.Placeholders,
+		HasName:           boolToInt(author.DisplayName != ""),
+		HasBio:            boolToInt(author.Bio != ""),
+		HasCompany:        boolToInt(author.Company != ""),
+		HasLocation:       boolToInt(author.Location != ""),
+		HasWebsite:        boolToInt(author.WebsiteURL != ""),
+		SiteAdmin:         boolToInt(author.IsSiteAdmin),
+		IsBot:             boolToInt(author.IsBot),
+		OrgMember:         boolToInt(author.Organizations > 0),
+		PublicRepos:       author.PublicRepos,
+		PublicGists:       author.PublicGists,
+		StarredRepos:      author.StarredRepos,
+		ActiveDays:        author.ActiveDays,
+		LongestStreak:     author.LongestStreak,
+		ChangedFiles:      issue.ChangedFiles,
+		Additions:         issue.Additions,
+		Deletions:         issue.Deletions,
+		DocsOnly:          boolToInt(DocsOnly(issue.Files)),
+		AuthorCommits:     issue.AuthorCommits,
+		Category:          categoryToClass[issue.Category],
+		Answered:          boolToInt(issue.Answered),
+	}
+
+	if issue.Parent != nil {
+		parentCreated, _ := time.Parse(time.RFC3339, issue.Parent.CreatedAt)
+		feats.CommentLinks = CountLinks(issue.Body)
+		feats.ParentSim = WordSimilarity(issue.Body, IssueText(*issue.Parent))
+		feats.ParentAge = int(issueCreated.Sub(parentCreated).Minutes())
+	}
+
+	if issue.IsSpam {
 		feats.IsSpam = 1
 	}
 	return feats
 }
 
-func downloadIssues(owner, repo string, limit int) ([]Issue, error) {
-	issues, err := GetNonSpam(owner, repo, limit)
+// IndexDupWindows indexes the repo's items of a kind opened in the
+// DupWindow before each of the times, at most DupLimit per window
+func IndexDupWindows(kind, owner, repo string, times []time.Time) (*DupIndex, error) {
+	sorted := append([]time.Time{}, times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	dups := NewDupIndex(DefaultDupThreshold)
+	var covered time.Time
+	for _, t := range sorted {
+		if !t.After(covered) {
+			continue
+		}
+		// fetch a whole window from where the last one ended, which
+		// covers the following times too
+		from := t.Add(-DupWindow)
+		if from.Before(covered) {
+			from = covered
+		}
+		to := from.Add(DupWindow)
+		if now := time.Now(); to.After(now) {
+			to = now
+		}
+		if to.Before(t) {
+			to = t
+		}
+		items, err := GetItemsCreated(kind, owner, repo, from, to, DupLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			dups.AddItem(item)
+		}
+		covered = to
+	}
+	return dups, nil
+}
+
+// Extractor computes features for issues in a repo, caching author lookups
+type Extractor struct {
+	Owner     string
+	Repo      string
+	Templates []Template
+
+	// Dups is an index of the repo's issues for finding near-duplicates
+	Dups *DupIndex
+
+	users    map[string]User
+	activity map[string]Activity
+}
+
+func NewExtractor(owner, repo string, templates []Template, dups *DupIndex) *Extractor {
+	return &Extractor{
+		Owner:     owner,
+		Repo:      repo,
+		Templates: templates,
+		Dups:      dups,
+		users:     map[string]User{},
+		activity:  map[string]Activity{},
+	}
+}
+
+// Extract gets the features of an issue, including its author's stats and history
+func (e *Extractor) Extract(issue Issue) (Features, error) {
+	// bots and users are cached apart, in case their logins are the same
+	username, key := issue.Author.Login, issue.Author.SearchLogin()
+	author, ok := e.users[key]
+	if !ok {
+		var err error
+		author, err = GetAuthorStats(issue.Author)
+		if err != nil {
+			return Features{}, fmt.Errorf("Error getting user stats for %s: %s", username, err)
+		}
+		e.users[key] = author
+	}
+
+	// the author's activity elsewhere is looked up around when the issue
+	// was posted, and again for issues outside of that
+	activity, ok := e.activity[key]
+	if !ok || !activity.Covers(PostedAt(issue)) {
+		var err error
+		activity, err = GetAuthorActivity(e.Owner, e.Repo, key, PostedAt(issue))
+		if err != nil {
+			return Features{}, fmt.Errorf("Error getting activity for %s: %s", username, err)
+		}
+		e.activity[key] = activity
+	}
+	return e.Features(issue, author, activity), nil
+}
+
+// Features computes the features of an issue from its author's stats and
+// activity, for callers that looked them up already
+func (e *Extractor) Features(issue Issue, author User, activity Activity) Features {
+	feat := ExtractFeatures(issue, author, e.Templates)
+	if e.Dups != nil {
+		feat.DupClusterSize = e.Dups.CountBefore(issue, DupWindow)
+	}
+
+	hist := activity.History(issue)
+	feat.PriorIssues = hist.PriorIssues
+	feat.PriorPRs = hist.PriorPRs
+	feat.QuickClosed = hist.QuickClosed
+	feat.MergedPRs = hist.MergedPRs
+	feat.CrossRepoIssues = hist.CrossRepoIssues
+	feat.PriorGap = hist.PriorGap
+	return feat
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var spamQueries = map[string]func(owner, repo string, limit int) ([]Issue, error){
+	KindIssue:      GetSpam,
+	KindPR:         GetSpamPulls,
+	KindDiscussion: GetSpamDiscussions,
+}
+
+var nonSpamQueries = map[string]func(owner, repo string, limit int) ([]Issue, error){
+	KindIssue:      GetNonSpam,
+	KindPR:         GetNonSpamPulls,
+	KindDiscussion: GetNonSpamDiscussions,
+}
+
+func downloadIssues(kind, owner, repo string, limit int, spamRatio float64) ([]Issue, error) {
+	if kind == "" {
+		kind = KindIssue
+	}
+	if kind == KindComment {
+		return downloadComments(owner, repo, limit, spamRatio)
+	}
+	getNonSpam, ok := nonSpamQueries[kind]
+	if !ok {
+		return nil, fmt.Errorf("Unknown kind %q", kind)
+	}
+
+	nonSpamLimit := limit - int(spamRatio*float64(limit))
+	issues, err := getNonSpam(owner, repo, nonSpamLimit)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(issues) == 0 {
-		return nil, fmt.Errorf("No issues found in %s/%s", owner, repo)
+		return nil, fmt.Errorf("No %ss found in %s/%s", kind, owner, repo)
 	}
 
 	limit -= len(issues)
-	spamIssues, err := GetSpam(owner, repo, limit)
+	spamIssues, err := spamQueries[kind](owner, repo, limit)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(spamIssues) == 0 {
-		return nil, fmt.Errorf("No spam issues found in %s/%s", owner, repo)
+		return nil, fmt.Errorf("No spam %ss found in %s/%s", kind, owner, repo)
 	}
 
 	for _, spamIssue := range spamIssues {
@@ -155,6 +456,58 @@ func downloadIssues(owner, repo string, limit int) ([]Issue, error) {
 	return issues, nil
 }
 
+// commentQuota keeps non-spam comments until they fill their share of
+// the limit, reserving the rest of it for spam
+type commentQuota struct {
+	nonSpam int
+}
+
+func newCommentQuota(limit int, spamRatio float64) *commentQuota {
+	return &commentQuota{nonSpam: limit - int(spamRatio*float64(limit))}
+}
+
+func (q *commentQuota) keep(comment Issue) bool {
+	if comment.IsSpam {
+		return true
+	}
+	if q.nonSpam == 0 {
+		return false
+	}
+	q.nonSpam--
+	return true
+}
+
+// downloadComments gets comments labeled by whether they were hidden as
+// spam. Comments can't be searched by whether they're hidden, so recent
+// comments are scanned until the spam ones fill their share of the limit.
+func downloadComments(owner, repo string, limit int, spamRatio float64) ([]Issue, error) {
+	q := fmt.Sprintf("repo:%s/%s comments:>0 sort:updated-desc", owner, repo)
+	client, err := gqlClient(true)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := commentSearchQuery(client, owner, repo, q, time.Time{}, limit, newCommentQuota(limit, spamRatio).keep)
+	if err != nil {
+		return nil, err
+	}
+
+	numSpam := 0
+	for _, comment := range comments {
+		if comment.IsSpam {
+			numSpam++
+		}
+	}
+	if len(comments) == numSpam {
+		return nil, fmt.Errorf("No comments found in %s/%s", owner, repo)
+	}
+	if numSpam == 0 {
+		return nil, fmt.Errorf("No comments hidden as spam found in %s/%s", owner, repo)
+	}
+
+	sort.Sort(byNumber(comments))
+	return comments, nil
+}
+
 type byNumber []Issue
 
 func (l byNumber) Len() int {
@@ -0,0 +1,211 @@
+package spam
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetLabels gets the current labels of an issue, pull request or discussion
+func GetLabels(kind, owner, repo string, number int) ([]string, error) {
+	field := "issueOrPullRequest"
+	fields := `
+      ... on Issue { labels(first: 100) { nodes { name } } }
+      ... on PullRequest { labels(first: 100) { nodes { name } } }`
+	if kind == KindDiscussion {
+		field = "discussion"
+		fields = `
+      labels(first: 100) { nodes { name } }`
+	}
+
+	query := `query GetLabels($owner: String!, $repo: String!, $number: Int!) {
+  repository(owner: $owner, name: $repo) {
+    item: ` + field + `(number: $number) {` + fields + `
+    }
+  }
+}`
+	resp := struct {
+		Repository struct {
+			Item *struct {
+				Labels struct{ Nodes []struct{ Name string } }
+			}
+		}
+	}{}
+	variables := map[string]interface{}{
+		"owner":  owner,
+		"repo":   repo,
+		"number": number,
+	}
+
+	client, err := gqlClient(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Do(query, variables, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Repository.Item == nil {
+		return nil, fmt.Errorf("%s #%d not found in %s/%s", kind, number, owner, repo)
+	}
+
+	labels := []string{}
+	for _, label := range resp.Repository.Item.Labels.Nodes {
+		labels = append(labels, label.Name)
+	}
+	return labels, nil
+}
+
+// Feedback is a maintainer's judgement of an item, read from its timeline
+type Feedback struct {
+	Number int
+	IsSpam bool
+	// Event is labeled, unlabeled or reopened
+	Event string
+	Actor string
+	At    time.Time
+}
+
+// GetViewer gets the login of the authenticated user
+func GetViewer() (string, error) {
+	client, err := gqlClient(false)
+	if err != nil {
+		return "", err
+	}
+	resp := struct{ Viewer struct{ Login string } }{}
+	if err := client.Do(`query { viewer { login } }`, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Viewer.Login, nil
+}
+
+const timelineFields = `
+        number
+        timelineItems(last: 100, itemTypes: [LABELED_EVENT, UNLABELED_EVENT, CLOSED_EVENT, REOPENED_EVENT]) {
+          nodes {
+            __typename
+            ... on LabeledEvent { createdAt actor { login } label { name } }
+            ... on UnlabeledEvent { createdAt actor { login } label { name } }
+            ... on ClosedEvent { createdAt actor { login } }
+            ... on ReopenedEvent { createdAt actor { login } }
+          }
+        }`
+
+type timelineEvent struct {
+	Typename  string `json:"__typename"`
+	CreatedAt time.Time
+	Actor     *struct{ Login string }
+	Label     *struct{ Name string }
+}
+
+// GetFeedback reads the timelines of issues or pull requests updated since a
+// time. Adding a spam label marks an item spam. Removing one, or reopening an
+// item that self closed, marks it not spam. Events by self are ignored, and
+// the latest event since the time wins.
+func GetFeedback(kind, owner, repo string, spamLabels []string, self string, since time.Time, limit int) ([]Feedback, error) {
+	if kind != KindIssue && kind != KindPR {
+		return nil, fmt.Errorf("Feedback is only read for issues and pull requests")
+	}
+	isType := "issue"
+	if kind == KindPR {
+		isType = "pr"
+	}
+	q := fmt.Sprintf("repo:%s/%s is:%s updated:>=%s sort:updated-desc",
+		owner, repo, isType, since.UTC().Format(time.RFC3339))
+
+	gqlQuery := `query SearchTimelines($query: String!, $after: String) {
+  search(query: $query, after: $after, type: ISSUE, first: 50) {
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    nodes {
+      ... on Issue {` + timelineFields + `
+      }
+      ... on PullRequest {` + timelineFields + `
+      }
+    }
+  }
+}`
+
+	client, err := gqlClient(false)
+	if err != nil {
+		return nil, err
+	}
+
+	isSpamLabel := func(name string) bool {
+		for _, label := range spamLabels {
+			if strings.EqualFold(name, label) {
+				return true
+			}
+		}
+		return false
+	}
+
+	feedback := []Feedback{}
+	seen := 0
+	variables := map[string]interface{}{"query": q}
+	for {
+		resp := struct {
+			Search struct {
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   string
+				}
+				Nodes []struct {
+					Number        int
+					TimelineItems struct{ Nodes []timelineEvent }
+				}
+			}
+		}{}
+		if err := client.Do(gqlQuery, variables, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, node := range resp.Search.Nodes {
+			var latest *Feedback
+			closedBySelf := false
+			for _, event := range node.TimelineItems.Nodes {
+				actor := ""
+				if event.Actor != nil {
+					actor = event.Actor.Login
+				}
+				bySelf := strings.EqualFold(actor, self)
+
+				f := Feedback{Number: node.Number, Actor: actor, At: event.CreatedAt}
+				switch event.Typename {
+				case "ClosedEvent":
+					closedBySelf = bySelf
+					continue
+				case "LabeledEvent", "UnlabeledEvent":
+					if event.Label == nil || !isSpamLabel(event.Label.Name) {
+						continue
+					}
+					f.IsSpam = event.Typename == "LabeledEvent"
+					f.Event = strings.ToLower(strings.TrimSuffix(event.Typename, "Event"))
+				case "ReopenedEvent":
+					if !closedBySelf {
+						continue
+					}
+					f.Event = "reopened"
+				}
+				if bySelf || event.CreatedAt.Before(since) {
+					continue
+				}
+				latest = &f
+			}
+			if latest != nil {
+				feedback = append(feedback, *latest)
+			}
+
+			seen++
+			if seen >= limit {
+				return feedback, nil
+			}
+		}
+
+		if !resp.Search.PageInfo.HasNextPage {
+			return feedback, nil
+		}
+		variables["after"] = resp.Search.PageInfo.EndCursor
+	}
+}
@@ -0,0 +1,218 @@
+package spam
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IssueFormDir is where a repo keeps its issue templates and forms
+const IssueFormDir = ".github/ISSUE_TEMPLATE"
+
+// IssueForm is a YAML issue form. Issues created from a form have a
+// "### Label" heading for each field, followed by its value.
+type IssueForm struct {
+	Name string      `yaml:"name"`
+	Body []FormField `yaml:"body"`
+}
+
+// FormField is an element of an issue form
+type FormField struct {
+	// Type is markdown, input, textarea, dropdown or checkboxes.
+	// Markdown elements are not included in the issue.
+	Type       string `yaml:"type"`
+	ID         string `yaml:"id"`
+	Attributes struct {
+		Label       string `yaml:"label"`
+		Placeholder string `yaml:"placeholder"`
+		Value       string `yaml:"value"`
+	} `yaml:"attributes"`
+	Validations struct {
+		Required bool `yaml:"required"`
+	} `yaml:"validations"`
+}
+
+// ParseIssueForm reads an issue form
+func ParseIssueForm(data []byte) (IssueForm, error) {
+	var form IssueForm
+	if err := yaml.Unmarshal(data, &form); err != nil {
+		return form, fmt.Errorf("Invalid issue form: %s", err)
+	}
+	if len(form.Body) == 0 {
+		return form, fmt.Errorf("Invalid issue form: no body")
+	}
+	return form, nil
+}
+
+// NewFormTemplate preprocesses an issue form for template matching
+func NewFormTemplate(form IssueForm) Template {
+	var body strings.Builder
+	sections := []section{}
+	for _, field := range form.Body {
+		label := field.Attributes.Label
+		if field.Type == "markdown" || label == "" {
+			continue
+		}
+		fmt.Fprintf(&body, "### %s\n\n%s\n\n", label, field.Attributes.Value)
+
+		s := section{heading: normalizeHeading(label), required: field.Validations.Required}
+		for _, text := range []string{field.Attributes.Placeholder, field.Attributes.Value} {
+			if text = normalizeSection(text); text != "" {
+				s.defaults = append(s.defaults, text)
+			}
+		}
+		sections = append(sections, s)
+	}
+
+	t := NewTemplates([]string{body.String()})[0]
+	t.sections = sections
+	return t
+}
+
+// isIssueForm reports whether a file in IssueFormDir is an issue form.
+// config.yml configures the template chooser and isn't a form. Its contact
+// links aren't parsed either: they send people elsewhere instead of giving
+// issues a body, so there is nothing to match an issue against.
+func isIssueForm(file string) bool {
+	ext := path.Ext(file)
+	base := strings.TrimSuffix(path.Base(file), ext)
+	return (ext == ".yml" || ext == ".yaml") && base != "config"
+}
+
+// GetIssueForms gets the repo's issue forms, preprocessed for template matching
+func GetIssueForms(owner, repo string) ([]Template, error) {
+	files, err := listRepoDir(owner, repo, IssueFormDir)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := []Template{}
+	for _, file := range files {
+		if !isIssueForm(file) {
+			continue
+		}
+		data, err := GetRepoFile(owner, repo, file)
+		if err != nil {
+			return nil, err
+		}
+		form, err := ParseIssueForm(data)
+		if err != nil {
+			// a broken form can't be used to open issues either
+			continue
+		}
+		templates = append(templates, NewFormTemplate(form))
+	}
+	return templates, nil
+}
+
+// section is a heading of a template and the text it is prefilled with
+type section struct {
+	heading  string
+	defaults []string
+	required bool
+}
+
+// templateSections returns the sections of a markdown template
+func templateSections(body string) []section {
+	sections := []section{}
+	for _, s := range splitSections(body) {
+		sec := section{heading: s[0]}
+		if s[1] != "" {
+			sec.defaults = []string{s[1]}
+		}
+		sections = append(sections, sec)
+	}
+	return sections
+}
+
+// splitSections splits text at its headings into normalized heading and
+// content pairs. Text before the first heading is dropped.
+func splitSections(text string) [][2]string {
+	sections := [][2]string{}
+	var content []string
+	flush := func() {
+		if len(sections) > 0 {
+			sections[len(sections)-1][1] = normalizeSection(strings.Join(content, "\n"))
+		}
+		content = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if h := headings(line); len(h) > 0 {
+			flush()
+			sections = append(sections, [2]string{h[0], ""})
+			continue
+		}
+		content = append(content, line)
+	}
+	flush()
+	return sections
+}
+
+var htmlCommentRE = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// normalizeSection lowercases text and collapses whitespace, dropping
+// HTML comments, which templates use for instructions
+func normalizeSection(text string) string {
+	text = htmlCommentRE.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func normalizeHeading(text string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Trim(text, "#* \t")))
+}
+
+// TemplateMatch describes how an issue body fills in its closest template
+type TemplateMatch struct {
+	// Sim is the similarity to the closest template, between 0 and 1
+	Sim float64
+	// HeadingsRemoved is the number of the template's headings missing from the body
+	HeadingsRemoved int
+	// EmptyRequired is the number of required form fields left empty
+	EmptyRequired int
+	// Placeholders is the number of sections left with the template's
+	// placeholder or default text
+	Placeholders int
+}
+
+// noResponse is what GitHub fills in for empty form fields
+const noResponse = "_no response_"
+
+// MatchTemplate compares the body to its most similar template
+func MatchTemplate(body string, templates []Template) TemplateMatch {
+	if body == "" || len(templates) == 0 {
+		return TemplateMatch{}
+	}
+
+	best, bestSim := 0, -1.0
+	for i, t := range templates {
+		if sim := t.Similarity(body); sim > bestSim {
+			best, bestSim = i, sim
+		}
+	}
+
+	match := TemplateMatch{Sim: bestSim}
+	filled := map[string]string{}
+	for _, s := range splitSections(body) {
+		filled[s[0]] = s[1]
+	}
+	for _, s := range templates[best].sections {
+		content, ok := filled[s.heading]
+		if !ok {
+			match.HeadingsRemoved++
+			continue
+		}
+		if s.required && (content == "" || content == noResponse) {
+			match.EmptyRequired++
+		}
+		for _, text := range s.defaults {
+			if content == text {
+				match.Placeholders++
+				break
+			}
+		}
+	}
+	return match
+}
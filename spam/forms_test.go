@@ -0,0 +1,78 @@
+package spam
+
+import "testing"
+
+const bugForm = `
+name: Bug report
+description: Report a bug
+body:
+  - type: markdown
+    attributes:
+      value: Thanks for taking the time to fill out this bug report!
+  - type: textarea
+    id: what-happened
+    attributes:
+      label: What happened?
+      placeholder: Tell us what you see
+    validations:
+      required: true
+  - type: input
+    id: version
+    attributes:
+      label: Version
+      value: "gh version "
+    validations:
+      required: true
+  - type: textarea
+    id: logs
+    attributes:
+      label: Logs
+`
+
+func TestMatchTemplateForm(t *testing.T) {
+	form, err := ParseIssueForm([]byte(bugForm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := []Template{NewFormTemplate(form)}
+
+	tests := []struct {
+		name string
+		body string
+		want TemplateMatch
+	}{
+		{
+			name: "filled in",
+			body: "### What happened?\n\npr create crashes\n\n### Version\n\ngh version 2.4.0\n\n### Logs\n\n_No response_",
+			want: TemplateMatch{Sim: 1},
+		},
+		{
+			name: "empty and unchanged fields",
+			body: "### What happened?\n\n_No response_\n\n### Version\n\ngh version\n\n### Logs\n\nbuy followers",
+			want: TemplateMatch{Sim: 1, EmptyRequired: 1, Placeholders: 1},
+		},
+		{
+			name: "headings removed",
+			body: "### What happened?\n\nTell us what you see",
+			want: TemplateMatch{Sim: 1.0 / 3, HeadingsRemoved: 2, Placeholders: 1},
+		},
+	}
+	for _, tt := range tests {
+		if got := MatchTemplate(tt.body, templates); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsIssueForm(t *testing.T) {
+	for file, want := range map[string]bool{
+		".github/ISSUE_TEMPLATE/bug.yml":       true,
+		".github/ISSUE_TEMPLATE/feature.yaml":  true,
+		".github/ISSUE_TEMPLATE/config.yml":    false,
+		".github/ISSUE_TEMPLATE/bug_report.md": false,
+	} {
+		if got := isIssueForm(file); got != want {
+			t.Errorf("isIssueForm(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package spam
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// issues closed within this time without comments were likely moderated
+	quickCloseWindow = 24 * time.Hour
+
+	// issues in other repos posted within this time of an issue count as a burst
+	crossRepoWindow = time.Hour
+
+	elsewhereLimit = 200
+)
+
+// Activity is the issues and pull requests an author opened
+type Activity struct {
+	// InRepo are the author's issues and PRs in the repo
+	InRepo []Issue
+
+	// Elsewhere are the author's issues in other repos created in
+	// [ElsewhereFrom, ElsewhereTo]. A zero ElsewhereFrom means all of them
+	// up to ElsewhereTo.
+	Elsewhere     []Issue
+	ElsewhereFrom time.Time
+	ElsewhereTo   time.Time
+}
+
+// History summarizes an author's activity before an issue
+type History struct {
+	PriorIssues     int
+	PriorPRs        int
+	QuickClosed     int
+	MergedPRs       int
+	CrossRepoIssues int
+
+	// PriorGap is the minutes since the author's previous issue or PR
+	// in the repo, or -1 if there was none
+	PriorGap int
+}
+
+// Gets an author's issues and pull requests in a repo, and their latest
+// issues outside of it around when an item was posted
+func GetAuthorActivity(owner, repo, username string, posted time.Time) (Activity, error) {
+	inRepo, err := GetUserIssues(owner, repo, username)
+	if err != nil {
+		return Activity{}, err
+	}
+	to := posted.Add(crossRepoWindow)
+	elsewhere, err := GetUserIssuesElsewhere(owner, repo, username, to, elsewhereLimit)
+	if err != nil {
+		return Activity{}, err
+	}
+
+	activity := Activity{InRepo: inRepo, Elsewhere: elsewhere, ElsewhereTo: to}
+	if len(elsewhere) >= elsewhereLimit {
+		// the search was cut off, so older issues may be missing
+		activity.ElsewhereFrom = to
+		for _, item := range elsewhere {
+			created, err := time.Parse(time.RFC3339, item.CreatedAt)
+			if err == nil && created.Before(activity.ElsewhereFrom) {
+				activity.ElsewhereFrom = created
+			}
+		}
+	}
+	return activity, nil
+}
+
+// PostedAt is when an issue was created, or now if that is unknown
+func PostedAt(issue Issue) time.Time {
+	posted, err := time.Parse(time.RFC3339, issue.CreatedAt)
+	if err != nil {
+		return time.Now()
+	}
+	return posted
+}
+
+// Covers reports whether the activity has all of the author's issues in
+// other repos within the cross-repo window of a time
+func (a Activity) Covers(posted time.Time) bool {
+	if a.ElsewhereTo.Before(posted.Add(crossRepoWindow)) {
+		return false
+	}
+	return a.ElsewhereFrom.IsZero() || !a.ElsewhereFrom.After(posted.Add(-crossRepoWindow))
+}
+
+// History computes the author's history as of when the issue was posted.
+// If the issue's creation time is unknown, all other activity counts as prior.
+func (a Activity) History(issue Issue) History {
+	hist := History{PriorGap: -1}
+	posted := PostedAt(issue)
+
+	for _, item := range a.InRepo {
+		if item.Number == issue.Number {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, item.CreatedAt)
+		if err != nil || !created.Before(posted) {
+			continue
+		}
+
+		if gap := int(posted.Sub(created).Minutes()); hist.PriorGap < 0 || gap < hist.PriorGap {
+			hist.PriorGap = gap
+		}
+
+		if item.Kind == KindPR {
+			hist.PriorPRs++
+			if item.Merged {
+				hist.MergedPRs++
+			}
+		} else {
+			hist.PriorIssues++
+		}
+
+		closed, err := time.Parse(time.RFC3339, item.ClosedAt)
+		if err == nil && item.Comments == 0 && closed.Sub(created) < quickCloseWindow {
+			hist.QuickClosed++
+		}
+	}
+
+	repos := map[string]bool{}
+	for _, item := range a.Elsewhere {
+		created, err := time.Parse(time.RFC3339, item.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if d := created.Sub(posted); d > -crossRepoWindow && d < crossRepoWindow {
+			repos[strings.ToLower(item.Repository.NameWithOwner)] = true
+		}
+	}
+	hist.CrossRepoIssues = len(repos)
+	return hist
+}
@@ -0,0 +1,64 @@
+package spam
+
+import (
+	"testing"
+	"time"
+)
+
+func historyItem(kind string, number int, repo, created, closed string, comments int) Issue {
+	item := Issue{Kind: kind, Number: number, CreatedAt: created, ClosedAt: closed, Comments: comments}
+	item.Repository.NameWithOwner = repo
+	return item
+}
+
+func TestHistory(t *testing.T) {
+	merged := historyItem(KindPR, 2, "cli/cli", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z", 1)
+	merged.Merged = true
+	activity := Activity{
+		InRepo: []Issue{
+			historyItem(KindIssue, 1, "cli/cli", "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z", 0),
+			merged,
+			historyItem(KindIssue, 3, "cli/cli", "2022-01-10T00:00:00Z", "", 0),
+			historyItem(KindIssue, 4, "cli/cli", "2022-01-20T00:00:00Z", "", 0),
+		},
+		Elsewhere: []Issue{
+			historyItem(KindIssue, 1, "a/a", "2022-01-09T23:30:00Z", "", 0),
+			historyItem(KindIssue, 2, "B/b", "2022-01-10T00:30:00Z", "", 0),
+			historyItem(KindIssue, 3, "b/b", "2022-01-10T00:40:00Z", "", 0),
+			historyItem(KindIssue, 4, "c/c", "2022-01-10T02:00:00Z", "", 0),
+		},
+		ElsewhereTo: time.Date(2022, 1, 10, 1, 0, 0, 0, time.UTC),
+	}
+
+	got := activity.History(activity.InRepo[2])
+	want := History{PriorIssues: 1, PriorPRs: 1, QuickClosed: 1, MergedPRs: 1, CrossRepoIssues: 2, PriorGap: 8 * 24 * 60}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got = activity.History(activity.InRepo[0])
+	want = History{PriorGap: -1}
+	if got != want {
+		t.Errorf("first issue: got %+v, want %+v", got, want)
+	}
+}
+
+func TestActivityCovers(t *testing.T) {
+	posted := time.Date(2022, 1, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		activity Activity
+		want     bool
+	}{
+		{"complete", Activity{ElsewhereTo: posted.Add(crossRepoWindow)}, true},
+		{"looked up before", Activity{ElsewhereTo: posted}, false},
+		{"cut off in the window", Activity{ElsewhereFrom: posted, ElsewhereTo: posted.Add(crossRepoWindow)}, false},
+		{"cut off before the window", Activity{ElsewhereFrom: posted.Add(-crossRepoWindow), ElsewhereTo: posted.Add(48 * time.Hour)}, true},
+		{"none", Activity{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.activity.Covers(posted); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
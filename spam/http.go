@@ -2,6 +2,7 @@ package spam
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cli/go-gh"
@@ -10,31 +11,162 @@ import (
 
 // GitHub User with profile info and contribution stats
 type User struct {
+	// Name is the user's login
 	Name               string
+	DisplayName        string
 	CreatedAt          string
 	Bio                string
+	Company            string
+	Location           string
+	WebsiteURL         string
+	IsSiteAdmin        bool
+	IsBot              bool
+	Organizations      int
+	PublicRepos        int
+	PublicGists        int
+	StarredRepos       int
 	Followers          int
 	Following          int
 	TotalContributions int
 	ReposContributed   int
+
+	// ActiveDays is the number of days with contributions in the last year,
+	// and LongestStreak the most consecutive of them
+	ActiveDays    int
+	LongestStreak int
+}
+
+// Kinds of items that can be classified
+const (
+	KindIssue      = "issue"
+	KindPR         = "pr"
+	KindDiscussion = "discussion"
+)
+
+// Actor is the author of an item, a user or a bot
+type Actor struct {
+	Login    string
+	Typename string `json:"__typename"`
+}
+
+// IsBot reports whether the actor is a bot. Bots have the type Bot in the
+// GraphQL API, and their logins end in [bot] in the REST API and webhooks.
+func (a Actor) IsBot() bool {
+	return a.Typename == "Bot" || strings.HasSuffix(a.Login, "[bot]")
+}
+
+// SearchLogin is the actor's login as used in search qualifiers like author:
+func (a Actor) SearchLogin() string {
+	if a.IsBot() {
+		return "app/" + strings.TrimSuffix(a.Login, "[bot]")
+	}
+	return a.Login
 }
 
+// Issue is an issue, pull request or discussion
 type Issue struct {
+	ID                string
+	Kind              string
 	Number            int
+	URL               string
 	Title             string
 	Body              string
-	Author            struct{ Login string }
+	Author            Actor
 	CreatedAt         string
+	ClosedAt          string
+	State             string
 	AuthorAssociation string
+	Repository        struct{ NameWithOwner string }
+	LinkedPRs         int
+	Comments          int
+	Edits             int
 	IsSpam            bool
+
+	// Pull request fields. AuthorCommits counts the PR's commits
+	// authored by the PR's author.
+	Merged        bool
+	ChangedFiles  int
+	Additions     int
+	Deletions     int
+	Files         []string
+	AuthorCommits int
+
+	// Discussion fields
+	Category string
+	Answered bool
+
+	// Parent is the issue or pull request a comment was posted on
+	Parent *Issue
+}
+
+// issueNode is an Issue as returned by GraphQL queries
+type issueNode struct {
+	Issue
+	Typename                       string `json:"__typename"`
+	Comments                       struct{ TotalCount int }
+	UserContentEdits               struct{ TotalCount int }
+	ClosedByPullRequestsReferences struct{ TotalCount int }
+
+	Closed     bool
+	IsAnswered bool
+	Category   struct{ Name string }
+	Files      struct{ Nodes []struct{ Path string } }
+	Commits    struct {
+		Nodes []struct {
+			Commit struct {
+				Author struct{ User struct{ Login string } }
+			}
+		}
+	}
+}
+
+func (n issueNode) toIssue() Issue {
+	issue := n.Issue
+	issue.LinkedPRs = n.ClosedByPullRequestsReferences.TotalCount
+	issue.Comments = n.Comments.TotalCount
+	issue.Edits = n.UserContentEdits.TotalCount
+
+	switch n.Typename {
+	case "PullRequest":
+		issue.Kind = KindPR
+		for _, file := range n.Files.Nodes {
+			issue.Files = append(issue.Files, file.Path)
+		}
+		for _, commit := range n.Commits.Nodes {
+			if strings.EqualFold(commit.Commit.Author.User.Login, issue.Author.Login) {
+				issue.AuthorCommits++
+			}
+		}
+	case "Discussion":
+		issue.Kind = KindDiscussion
+		issue.Category = n.Category.Name
+		issue.Answered = n.IsAnswered
+		if n.Closed {
+			issue.State = "CLOSED"
+		} else {
+			issue.State = "OPEN"
+		}
+	default:
+		issue.Kind = KindIssue
+	}
+	return issue
+}
+
+// Gets the stats of an item's author. Bots aren't users in the GraphQL
+// API, so they have no stats.
+func GetAuthorStats(author Actor) (User, error) {
+	if author.IsBot() {
+		return User{Name: author.Login, IsBot: true}, nil
+	}
+	return GetUserStats(author.Login)
 }
 
 // Gets summary of GitHub user's account and contributions
 func GetUserStats(username string) (User, error) {
 	usr := User{}
+
 	timeout, _ := time.ParseDuration("2s")
-	opts := &api.ClientOptions{EnableCache: true, Timeout: timeout}
-	client, err := gh.GQLClient(opts)
+	client, err := gh.GQLClient(clientOptions(true, timeout))
 	if err != nil {
 		return usr, err
 	}
@@ -42,11 +174,23 @@ func GetUserStats(username string) (User, error) {
 	query := `query GetUserStats($username: String!) {
   user(login: $username) {
     createdAt
+    name
     bio
+    company
+    location
+    websiteUrl
+    isSiteAdmin
+    organizations{ totalCount }
+    repositories(privacy: PUBLIC){ totalCount }
+    gists(privacy: PUBLIC){ totalCount }
+    starredRepositories{ totalCount }
     followers{ totalCount }
     following{ totalCount }
     contributionsCollection {
-      contributionCalendar { totalContributions }
+      contributionCalendar {
+        totalContributions
+        weeks { contributionDays { contributionCount } }
+      }
     }
     repositoriesContributedTo(
 		first:100, 
@@ -61,11 +205,25 @@ func GetUserStats(username string) (User, error) {
 	resp := struct {
 		User struct {
 			CreatedAt               string
+			Name                    string
 			Bio                     string
+			Company                 string
+			Location                string
+			WebsiteURL              string
+			IsSiteAdmin             bool
+			Organizations           struct{ TotalCount int }
+			Repositories            struct{ TotalCount int }
+			Gists                   struct{ TotalCount int }
+			StarredRepositories     struct{ TotalCount int }
 			Followers               struct{ TotalCount int }
 			Following               struct{ TotalCount int }
 			ContributionsCollection struct {
-				ContributionCalendar struct{ TotalContributions int }
+				ContributionCalendar struct {
+					TotalContributions int
+					Weeks              []struct {
+						ContributionDays []struct{ ContributionCount int }
+					}
+				}
 			}
 			RepositoriesContributedTo struct{ TotalCount int }
 		}
@@ -75,18 +233,45 @@ func GetUserStats(username string) (User, error) {
 		return usr, err
 	}
 
+	calendar := resp.User.ContributionsCollection.ContributionCalendar
 	usr = User{
 		Name:               username,
+		DisplayName:        resp.User.Name,
 		CreatedAt:          resp.User.CreatedAt,
+		Bio:                resp.User.Bio,
+		Company:            resp.User.Company,
+		Location:           resp.User.Location,
+		WebsiteURL:         resp.User.WebsiteURL,
+		IsSiteAdmin:        resp.User.IsSiteAdmin,
+		Organizations:      resp.User.Organizations.TotalCount,
+		PublicRepos:        resp.User.Repositories.TotalCount,
+		PublicGists:        resp.User.Gists.TotalCount,
+		StarredRepos:       resp.User.StarredRepositories.TotalCount,
 		Followers:          resp.User.Followers.TotalCount,
 		Following:          resp.User.Following.TotalCount,
-		TotalContributions: resp.User.ContributionsCollection.ContributionCalendar.TotalContributions,
+		TotalContributions: calendar.TotalContributions,
 		ReposContributed:   resp.User.RepositoriesContributedTo.TotalCount,
 	}
+
+	streak := 0
+	for _, week := range calendar.Weeks {
+		for _, day := range week.ContributionDays {
+			if day.ContributionCount == 0 {
+				streak = 0
+				continue
+			}
+			usr.ActiveDays++
+			streak++
+			if streak > usr.LongestStreak {
+				usr.LongestStreak = streak
+			}
+		}
+	}
 	return usr, nil
 }
 
-func GetTemplates(owner, repo string) ([]string, error) {
+// Gets the repo's issue templates and forms, preprocessed for similarity scoring
+func GetTemplates(owner, repo string) ([]Template, error) {
 	query := `query GetIssueTemplates($owner: String!, $repo: String!) {
   	repository(owner: $owner, name: $repo) { issueTemplates { body } } }`
 
@@ -97,8 +282,7 @@ func GetTemplates(owner, repo string) ([]string, error) {
 		}
 	}{}
 
-	opts := &api.ClientOptions{EnableCache: true}
-	client, err := gh.GQLClient(opts)
+	client, err := gqlClient(true)
 	if err != nil {
 		return nil, err
 	}
@@ -107,19 +291,87 @@ func GetTemplates(owner, repo string) ([]string, error) {
 		return nil, err
 	}
 
-	templates := []string{}
+	bodies := []string{}
 	for _, body := range resp.Repository.IssueTemplates {
-		templates = append(templates, body.Body)
+		if body.Body != "" {
+			bodies = append(bodies, body.Body)
+		}
 	}
-	return templates, nil
+
+	forms, err := GetIssueForms(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return append(NewTemplates(bodies), forms...), nil
 }
 
-// Gets issues opened by an author in a repo
+// Gets issues and pull requests opened by an author in a repo
 func GetUserIssues(owner, repo, username string) ([]Issue, error) {
-	searchQuery := fmt.Sprintf("repo:%s/%s is:issue author:%s", owner, repo, username)
+	searchQuery := fmt.Sprintf("repo:%s/%s author:%s", owner, repo, username)
 	return issueSearchQuery(searchQuery, 1000)
 }
 
+// Gets the latest issues opened by an author in other repos up to a time
+func GetUserIssuesElsewhere(owner, repo, username string, until time.Time, limit int) ([]Issue, error) {
+	searchQuery := fmt.Sprintf("-repo:%s/%s is:issue author:%s created:<=%s sort:created-desc",
+		owner, repo, username, until.UTC().Format(time.RFC3339))
+	return issueSearchQuery(searchQuery, limit)
+}
+
+// Gets issues created since a time, newest first
+func GetRecentIssues(owner, repo string, since time.Time, limit int) ([]Issue, error) {
+	searchQuery := fmt.Sprintf("repo:%s/%s is:issue created:>=%s sort:created-desc",
+		owner, repo, since.UTC().Format(time.RFC3339))
+	return recentSearchQuery(searchQuery, "ISSUE", issueFields+pullFields, limit)
+}
+
+// Gets open issues created since a time, newest first
+func GetOpenIssues(owner, repo string, since time.Time, limit int) ([]Issue, error) {
+	searchQuery := fmt.Sprintf("repo:%s/%s is:issue is:open created:>=%s sort:created-desc",
+		owner, repo, since.UTC().Format(time.RFC3339))
+	return recentSearchQuery(searchQuery, "ISSUE", issueFields+pullFields, limit)
+}
+
+// Gets the logins of a team's members
+func GetTeamMembers(org, team string) ([]string, error) {
+	client, err := restClient(true)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []string{}
+	for page := 1; ; page++ {
+		resp := []struct{ Login string }{}
+		path := fmt.Sprintf("orgs/%s/teams/%s/members?per_page=100&page=%d", org, team, page)
+		if err := client.Get(path, &resp); err != nil {
+			return nil, err
+		}
+		for _, member := range resp {
+			members = append(members, member.Login)
+		}
+		if len(resp) < 100 {
+			return members, nil
+		}
+	}
+}
+
+// Checks if a user is a public member of an org
+func IsOrgMember(org, username string) (bool, error) {
+	client, err := restClient(true)
+	if err != nil {
+		return false, err
+	}
+
+	path := fmt.Sprintf("orgs/%s/members/%s", org, username)
+	if err := client.Get(path, nil); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // Finds issues that were likely closed as spam
 func GetSpam(owner, repo string, limit int) ([]Issue, error) {
 	searchQuery := fmt.Sprintf("repo:%s/%s is:issue is:closed comments:0 -linked:pr", owner, repo)
@@ -140,29 +392,85 @@ func GetNonSpam(owner, repo string, limit int) ([]Issue, error) {
 	return issueSearchQuery(searchQuery, limit)
 }
 
+// GraphQL fields for each kind of item
+const (
+	commonFields = `
+        id
+        author { __typename login }
+        title
+        body
+        number
+        authorAssociation
+        createdAt
+        closedAt
+        repository { nameWithOwner }
+        comments { totalCount }
+        userContentEdits { totalCount }`
+
+	issueFields = `
+      ... on Issue {` + commonFields + `
+        state
+        closedByPullRequestsReferences(first: 1, includeClosedPrs: true) { totalCount }
+      }`
+
+	pullFields = `
+      ... on PullRequest {` + commonFields + `
+        state
+        merged
+      }`
+
+	pullDetailFields = `
+      ... on PullRequest {` + commonFields + `
+        state
+        merged
+        changedFiles
+        additions
+        deletions
+        files(first: 100) { nodes { path } }
+        commits(first: 100) { nodes { commit { author { user { login } } } } }
+      }`
+
+	discussionFields = `
+      ... on Discussion {` + commonFields + `
+        closed
+        isAnswered
+        category { name }
+      }`
+)
+
 func issueSearchQuery(query string, limit int) ([]Issue, error) {
-	opts := &api.ClientOptions{EnableCache: true}
-	client, err := gh.GQLClient(opts)
+	return searchQuery(query, "ISSUE", issueFields+pullFields, limit)
+}
+
+// searchQuery pages through search results of a type, decoding the given node fields
+func searchQuery(query, searchType, fields string, limit int) ([]Issue, error) {
+	client, err := gqlClient(true)
 	if err != nil {
 		return nil, err
 	}
+	return search(client, query, searchType, fields, limit)
+}
 
-	gqlQuery := `query GetSpamIssues($query: String!, $after: String) {
-search(query: $query, after: $after, type: ISSUE, first: 100) {
+// recentSearchQuery is searchQuery for recent items, which are cached only
+// briefly so new ones are found
+func recentSearchQuery(query, searchType, fields string, limit int) ([]Issue, error) {
+	client, err := liveGQLClient()
+	if err != nil {
+		return nil, err
+	}
+	return search(client, query, searchType, fields, limit)
+}
+
+func search(client api.GQLClient, query, searchType, fields string, limit int) ([]Issue, error) {
+	gqlQuery := `query Search($query: String!, $after: String) {
+search(query: $query, after: $after, type: ` + searchType + `, first: 100) {
     pageInfo {
 	  startCursor
       hasNextPage
       endCursor
     }
     nodes {
-      ... on Issue {
-        author { login }
-        title
-		body
-        number
-        authorAssociation
-		createdAt
-      }
+      __typename` + fields + `
     }
   }
 }`
@@ -177,16 +485,16 @@ search(query: $query, after: $after, type: ISSUE, first: 100) {
 					HasNextPage bool
 					EndCursor   string
 				}
-				Nodes []Issue
+				Nodes []issueNode
 			}
 		}{}
 
-		err = client.Do(gqlQuery, variables, &resp)
-		if err != nil {
+		if err := client.Do(gqlQuery, variables, &resp); err != nil {
 			return nil, err
 		}
 
-		for _, issue := range resp.Search.Nodes {
+		for _, node := range resp.Search.Nodes {
+			issue := node.toIssue()
 			if issue.Title != "" && issue.Author.Login != "" {
 				issues = append(issues, issue)
 			}
@@ -203,24 +511,26 @@ search(query: $query, after: $after, type: ISSUE, first: 100) {
 }
 
 func GetIssueByNumber(owner, repo string, number int) (Issue, error) {
-	query := `query GetIssue($owner: String!, $repo: String!, $number: Int!) {
+	return getByNumber(owner, repo, "issue", issueFields, number)
+}
+
+// getByNumber queries a repo's item of a kind by number, decoding the given fields
+func getByNumber(owner, repo, field, fields string, number int) (Issue, error) {
+	query := `query GetItem($owner: String!, $repo: String!, $number: Int!) {
   repository(owner: $owner, name: $repo) {
-    issue(number: $number) {
-      author { login }
-      title
-      body
-      authorAssociation
+    item: ` + field + `(number: $number) {
+      __typename` + fields + `
     }
   }
 }`
-	resp := struct{ Repository struct{ Issue Issue } }{}
+	resp := struct{ Repository struct{ Item *issueNode } }{}
 	variables := map[string]interface{}{
 		"owner":  owner,
 		"repo":   repo,
 		"number": number,
 	}
 
-	client, err := gh.GQLClient(nil)
+	client, err := liveGQLClient()
 	if err != nil {
 		return Issue{}, err
 	}
@@ -228,7 +538,11 @@ func GetIssueByNumber(owner, repo string, number int) (Issue, error) {
 	if err != nil {
 		return Issue{}, err
 	}
+	if resp.Repository.Item == nil {
+		return Issue{}, fmt.Errorf("%s #%d not found in %s/%s", field, number, owner, repo)
+	}
 
-	resp.Repository.Issue.Number = number
-	return resp.Repository.Issue, nil
+	issue := resp.Repository.Item.toIssue()
+	issue.Number = number
+	return issue, nil
 }
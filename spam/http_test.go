@@ -0,0 +1,32 @@
+package spam
+
+import "testing"
+
+func TestActor(t *testing.T) {
+	tests := []struct {
+		actor  Actor
+		bot    bool
+		search string
+	}{
+		{Actor{Login: "monalisa", Typename: "User"}, false, "monalisa"},
+		{Actor{Login: "dependabot", Typename: "Bot"}, true, "app/dependabot"},
+		{Actor{Login: "dependabot[bot]"}, true, "app/dependabot"},
+	}
+	for _, tt := range tests {
+		if got := tt.actor.IsBot(); got != tt.bot {
+			t.Errorf("%+v: got bot %v, want %v", tt.actor, got, tt.bot)
+		}
+		if got := tt.actor.SearchLogin(); got != tt.search {
+			t.Errorf("%+v: got search login %q, want %q", tt.actor, got, tt.search)
+		}
+	}
+
+	// bots have no user to look up
+	user, err := GetAuthorStats(Actor{Login: "dependabot", Typename: "Bot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsBot || user.Name != "dependabot" {
+		t.Errorf("got %+v, want the bot", user)
+	}
+}
@@ -0,0 +1,172 @@
+package spam
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LocalConfig reads the policy from a checkout of the repo, falling back
+// to the default config if there is none
+func LocalConfig(root string) (Config, error) {
+	cfg, err := LoadConfig(filepath.Join(root, ConfigPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultConfig(), nil
+	}
+	return cfg, err
+}
+
+// templateDirs are where GitHub looks for single-file templates
+var templateDirs = []string{".", ".github", "docs"}
+
+// LocalTemplates reads the templates to compare items of a kind against
+// from a checkout of the repo
+func LocalTemplates(kind, root string) ([]Template, error) {
+	templates := []Template{}
+	var dir, single string
+	switch kind {
+	case KindIssue:
+		dir, single = IssueFormDir, "issue_template.md"
+	case KindPR:
+		dir, single = ".github/PULL_REQUEST_TEMPLATE", "pull_request_template.md"
+	default:
+		return templates, nil
+	}
+
+	bodies := []string{}
+	for _, d := range templateDirs {
+		files, err := readDir(filepath.Join(root, d))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if strings.EqualFold(filepath.Base(file), single) {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return nil, err
+				}
+				bodies = append(bodies, stripFrontMatter(string(data)))
+			}
+		}
+	}
+
+	files, err := readDir(filepath.Join(root, dir))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.EqualFold(filepath.Ext(file), ".md"):
+			bodies = append(bodies, stripFrontMatter(string(data)))
+		case kind == KindIssue && isIssueForm(filepath.ToSlash(file)):
+			if form, err := ParseIssueForm(data); err == nil {
+				templates = append(templates, NewFormTemplate(form))
+			}
+		}
+	}
+	return append(NewTemplates(bodies), templates...), nil
+}
+
+// readDir lists the files in a directory. A missing directory is empty.
+func readDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// stripFrontMatter removes the YAML header of a markdown issue template,
+// which isn't part of the issue body
+func stripFrontMatter(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return text
+	}
+	end := strings.Index(text[4:], "\n---")
+	if end < 0 {
+		return text
+	}
+	rest := text[4+end+len("\n---"):]
+	return strings.TrimLeft(rest, "-\n")
+}
+
+var (
+	codeownersPaths  = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+	maintainersPaths = []string{"MAINTAINERS", "MAINTAINERS.md", "MAINTAINERS.txt", ".github/MAINTAINERS"}
+
+	mentionRE     = regexp.MustCompile(`(?:^|[^\w.@/])@([A-Za-z0-9][A-Za-z0-9-]*(?:/[A-Za-z0-9_.-]+)?)`)
+	profileLinkRE = regexp.MustCompile(`github\.com/([A-Za-z0-9][A-Za-z0-9-]*)(?:[^\w/-]|$)`)
+)
+
+// LocalMaintainers reads the users and teams (as org/team-slug) named in
+// the CODEOWNERS and MAINTAINERS files of a checkout of the repo
+func LocalMaintainers(root string) (users, teams []string, err error) {
+	seen := map[string]bool{}
+	add := func(name string) {
+		key := strings.ToLower(name)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if strings.Contains(name, "/") {
+			teams = append(teams, name)
+		} else {
+			users = append(users, name)
+		}
+	}
+
+	for _, path := range codeownersPaths {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			// owners follow the path pattern; emails aren't GitHub users
+			for _, owner := range fields[1:] {
+				if strings.HasPrefix(owner, "#") {
+					break
+				}
+				if strings.HasPrefix(owner, "@") {
+					add(strings.TrimPrefix(owner, "@"))
+				}
+			}
+		}
+	}
+
+	for _, path := range maintainersPaths {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range mentionRE.FindAllStringSubmatch(string(data), -1) {
+			add(m[1])
+		}
+		for _, m := range profileLinkRE.FindAllStringSubmatch(string(data), -1) {
+			add(m[1])
+		}
+	}
+	return users, teams, nil
+}
@@ -0,0 +1,102 @@
+package spam
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles writes files under a root directory
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLocalConfig(t *testing.T) {
+	root := t.TempDir()
+	cfg, err := LocalConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("got %+v without a policy, want the default", cfg)
+	}
+
+	writeFiles(t, root, map[string]string{ConfigPath: "thresholds:\n  spam: 0.7\n"})
+	if cfg, err = LocalConfig(root); err != nil || cfg.Thresholds.Spam != 0.7 {
+		t.Errorf("got spam threshold %v, %v, want 0.7", cfg.Thresholds.Spam, err)
+	}
+}
+
+func TestLocalTemplates(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".github/ISSUE_TEMPLATE/bug_report.md": "---\nname: Bug report\nabout: Report a bug\n---\n### Describe the bug\n\nA clear description.\n",
+		".github/ISSUE_TEMPLATE/feature.yml":   "name: Feature\nbody:\n  - type: textarea\n    attributes:\n      label: What do you want?\n",
+		".github/ISSUE_TEMPLATE/config.yml":    "blank_issues_enabled: false\ncontact_links:\n  - name: Support\n    url: https://github.com/cli/cli/discussions\n",
+		"docs/pull_request_template.md":        "## Summary\n\nWhat does this change?\n",
+	})
+
+	issues, err := LocalTemplates(KindIssue, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("got %d issue templates, want the markdown template and the form", len(issues))
+	}
+	if match := MatchTemplate("### Describe the bug\n\nA clear description.", issues); match.Sim != 1 {
+		t.Errorf("got %+v, want the front matter stripped", match)
+	}
+
+	pulls, err := LocalTemplates(KindPR, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pulls) != 1 {
+		t.Errorf("got %d pull request templates, want 1", len(pulls))
+	}
+
+	if none, err := LocalTemplates(KindComment, root); err != nil || len(none) != 0 {
+		t.Errorf("got %d comment templates, %v, want none", len(none), err)
+	}
+}
+
+func TestStripFrontMatter(t *testing.T) {
+	for text, want := range map[string]string{
+		"---\r\nname: Bug\r\n---\r\nBody\r\n": "Body\n",
+		"No front matter":                     "No front matter",
+		"---\nunterminated":                   "---\nunterminated",
+	} {
+		if got := stripFrontMatter(text); got != want {
+			t.Errorf("stripFrontMatter(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestLocalMaintainers(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".github/CODEOWNERS": "# owners\n* @monalisa @cli/maintainers # core\n/docs/ docs@example.com @Hubot\n",
+		"MAINTAINERS.md":     "- @hubot\n- [Octocat](https://github.com/octocat)\n- email me@example.com\n",
+	})
+
+	users, teams, err := LocalMaintainers(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"monalisa", "Hubot", "octocat"}; !reflect.DeepEqual(users, want) {
+		t.Errorf("got users %v, want %v", users, want)
+	}
+	if want := []string{"cli/maintainers"}; !reflect.DeepEqual(teams, want) {
+		t.Errorf("got teams %v, want %v", teams, want)
+	}
+}
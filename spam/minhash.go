@@ -0,0 +1,295 @@
+package spam
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	shingleSize = 3
+
+	// LSH bands of the MinHash signature. Documents sharing all rows of
+	// any band are compared; with 20 bands of 5 rows, pairs with
+	// Jaccard similarity above ~0.55 are likely to become candidates.
+	numBands = 20
+	bandRows = 5
+	numHash  = numBands * bandRows
+)
+
+// DefaultDupThreshold is the estimated Jaccard similarity of near-duplicates
+const DefaultDupThreshold = 0.8
+
+// Near-duplicates of an item are counted among the repo's items opened in
+// the DupWindow before it. At most DupLimit items are indexed per window,
+// both for training and for classifying.
+const (
+	DupWindow = 30 * 24 * time.Hour
+	DupLimit  = 1000
+)
+
+var hashSeeds = func() []uint64 {
+	r := rand.New(rand.NewSource(1))
+	seeds := make([]uint64, numHash)
+	for i := range seeds {
+		seeds[i] = r.Uint64()
+	}
+	return seeds
+}()
+
+// Signature is the MinHash signature of a document's word shingles
+type Signature []uint64
+
+// MinHash computes the signature of text, or nil if it is too short to shingle
+func MinHash(text string) Signature {
+	shingles := wordShingles(text, shingleSize)
+	if len(shingles) == 0 {
+		return nil
+	}
+
+	sig := make(Signature, numHash)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for sh := range shingles {
+		for i, seed := range hashSeeds {
+			if h := mix64(sh ^ seed); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// Similarity estimates the Jaccard similarity of the documents
+func (s Signature) Similarity(other Signature) float64 {
+	if len(s) == 0 || len(s) != len(other) {
+		return 0
+	}
+	same := 0
+	for i := range s {
+		if s[i] == other[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(s))
+}
+
+// DupIndex finds near-duplicate documents with locality sensitive hashing.
+// It is safe for concurrent use.
+type DupIndex struct {
+	Threshold float64
+
+	// mu guards the index; even queries compress the clusters' paths
+	mu      sync.Mutex
+	sigs    map[int]Signature
+	buckets []map[uint64][]int
+	parent  map[int]int
+	size    map[int]int
+	created map[int]time.Time
+}
+
+func NewDupIndex(threshold float64) *DupIndex {
+	idx := &DupIndex{
+		Threshold: threshold,
+		sigs:      map[int]Signature{},
+		buckets:   make([]map[uint64][]int, numBands),
+		parent:    map[int]int{},
+		size:      map[int]int{},
+		created:   map[int]time.Time{},
+	}
+	for i := range idx.buckets {
+		idx.buckets[i] = map[uint64][]int{}
+	}
+	return idx
+}
+
+// IssueText is the text of an issue used for duplicate detection
+func IssueText(issue Issue) string {
+	return issue.Title + "\n" + issue.Body
+}
+
+// Add indexes a document by id, joining it to the clusters of its near-duplicates
+func (idx *DupIndex) Add(id int, text string) {
+	sig := MinHash(text)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.add(id, sig)
+}
+
+func (idx *DupIndex) add(id int, sig Signature) {
+	idx.parent[id] = id
+	idx.size[id] = 1
+	if sig == nil {
+		return
+	}
+
+	for _, other := range idx.query(sig) {
+		idx.union(id, other)
+	}
+	idx.sigs[id] = sig
+	for b := 0; b < numBands; b++ {
+		key := bandKey(sig, b)
+		idx.buckets[b][key] = append(idx.buckets[b][key], id)
+	}
+}
+
+// AddItem indexes an item by number, recording when it was created.
+// Items already indexed are skipped.
+func (idx *DupIndex) AddItem(issue Issue) {
+	sig := MinHash(IssueText(issue))
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.parent[issue.Number]; ok {
+		return
+	}
+	if created, err := time.Parse(time.RFC3339, issue.CreatedAt); err == nil {
+		idx.created[issue.Number] = created
+	}
+	idx.add(issue.Number, sig)
+}
+
+// CountBefore is 1 plus the number of indexed near-duplicates of an item
+// created in the window before it. Items with unknown creation times are
+// counted, and an item with an unknown creation time is taken to be new.
+func (idx *DupIndex) CountBefore(issue Issue, window time.Duration) int {
+	created, err := time.Parse(time.RFC3339, issue.CreatedAt)
+	if err != nil {
+		created = time.Now()
+	}
+	sig := MinHash(IssueText(issue))
+	if sig == nil {
+		return 1
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	count := 1
+	for _, id := range idx.query(sig) {
+		if id == issue.Number {
+			continue
+		}
+		if t, ok := idx.created[id]; ok && (t.After(created) || t.Before(created.Add(-window))) {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// Query returns the ids of indexed near-duplicates of text
+func (idx *DupIndex) Query(text string) []int {
+	sig := MinHash(text)
+	if sig == nil {
+		return nil
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.query(sig)
+}
+
+func (idx *DupIndex) query(sig Signature) []int {
+	seen := map[int]bool{}
+	dups := []int{}
+	for b := 0; b < numBands; b++ {
+		for _, id := range idx.buckets[b][bandKey(sig, b)] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if sig.Similarity(idx.sigs[id]) >= idx.Threshold {
+				dups = append(dups, id)
+			}
+		}
+	}
+	sort.Ints(dups)
+	return dups
+}
+
+// ClusterSize is the number of documents in id's cluster, including itself
+func (idx *DupIndex) ClusterSize(id int) int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.parent[id]; !ok {
+		return 0
+	}
+	return idx.size[idx.find(id)]
+}
+
+// Clusters returns the groups of two or more near-duplicates,
+// largest first, each sorted by id
+func (idx *DupIndex) Clusters() [][]int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	groups := map[int][]int{}
+	for id := range idx.parent {
+		root := idx.find(id)
+		groups[root] = append(groups[root], id)
+	}
+
+	clusters := [][]int{}
+	for _, group := range groups {
+		if len(group) > 1 {
+			sort.Ints(group)
+			clusters = append(clusters, group)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i]) != len(clusters[j]) {
+			return len(clusters[i]) > len(clusters[j])
+		}
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
+
+func (idx *DupIndex) find(id int) int {
+	for idx.parent[id] != id {
+		idx.parent[id] = idx.parent[idx.parent[id]]
+		id = idx.parent[id]
+	}
+	return id
+}
+
+func (idx *DupIndex) union(a, b int) {
+	ra, rb := idx.find(a), idx.find(b)
+	if ra != rb {
+		idx.parent[ra] = rb
+		idx.size[rb] += idx.size[ra]
+	}
+}
+
+func bandKey(sig Signature, band int) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, v := range sig[band*bandRows : (band+1)*bandRows] {
+		for i := range buf {
+			buf[i] = byte(v >> (8 * i))
+		}
+		h.Write(buf)
+	}
+	return h.Sum64()
+}
+
+// wordShingles hashes each run of k consecutive lowercase words
+func wordShingles(text string, k int) map[uint64]struct{} {
+	words := strings.Fields(strings.ToLower(text))
+	shingles := map[uint64]struct{}{}
+	for i := 0; i+k <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+k], " ")))
+		shingles[h.Sum64()] = struct{}{}
+	}
+	return shingles
+}
+
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,105 @@
+package spam
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// randomText makes a text of n words from a large vocabulary
+func randomText(rng *rand.Rand, n int) []string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", rng.Intn(5000))
+	}
+	return words
+}
+
+func TestDupIndexRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	idx := NewDupIndex(DefaultDupThreshold)
+	originals := [][]string{}
+	for i := 0; i < 200; i++ {
+		words := randomText(rng, 80)
+		originals = append(originals, words)
+		idx.Add(i, strings.Join(words, " "))
+	}
+
+	// near-duplicates change one word in 80; unrelated texts share nothing
+	found, falsePositives := 0, 0
+	for i, words := range originals {
+		edited := append([]string{}, words...)
+		edited[rng.Intn(len(edited))] = "changed"
+		if dups := idx.Query(strings.Join(edited, " ")); reflect.DeepEqual(dups, []int{i}) {
+			found++
+		}
+		falsePositives += len(idx.Query(strings.Join(randomText(rng, 80), " ")))
+	}
+	if found < 195 {
+		t.Errorf("found %d of 200 near-duplicates, want at least 195", found)
+	}
+	if falsePositives > 0 {
+		t.Errorf("got %d false positives among unrelated texts", falsePositives)
+	}
+}
+
+func TestDupIndexClusters(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	wave := randomText(rng, 40)
+	idx := NewDupIndex(DefaultDupThreshold)
+	for id := 1; id <= 3; id++ {
+		text := append([]string{}, wave...)
+		text[id] = "edit"
+		idx.Add(id, strings.Join(text, " "))
+	}
+	idx.Add(4, strings.Join(randomText(rng, 40), " "))
+	idx.Add(5, "too short")
+
+	if got := idx.Clusters(); !reflect.DeepEqual(got, [][]int{{1, 2, 3}}) {
+		t.Errorf("got clusters %v, want [[1 2 3]]", got)
+	}
+	for id, want := range map[int]int{1: 3, 4: 1, 5: 1, 6: 0} {
+		if got := idx.ClusterSize(id); got != want {
+			t.Errorf("got cluster size %d for %d, want %d", got, id, want)
+		}
+	}
+}
+
+func TestCountBefore(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	text := strings.Join(randomText(rng, 40), " ")
+	day := func(d int) string {
+		return time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	}
+	item := func(number int, created string) Issue {
+		return Issue{Number: number, Title: text, CreatedAt: created}
+	}
+
+	idx := NewDupIndex(DefaultDupThreshold)
+	idx.AddItem(item(1, day(1)))
+	idx.AddItem(item(2, day(20)))
+	idx.AddItem(item(3, day(25)))
+	idx.AddItem(item(4, day(28)))
+	idx.AddItem(item(4, day(28)))
+
+	window := 7 * 24 * time.Hour
+	tests := []struct {
+		issue Issue
+		want  int
+	}{
+		// #4 itself doesn't count, and #1 and #2 are outside the window
+		{item(4, day(28)), 2},
+		{item(3, day(25)), 2},
+		{item(1, day(1)), 1},
+		{item(9, day(31)), 3},
+		{Issue{Number: 10, Title: "something else entirely, not a duplicate", CreatedAt: day(28)}, 1},
+	}
+	for _, tt := range tests {
+		if got := idx.CountBefore(tt.issue, window); got != tt.want {
+			t.Errorf("#%d: got %d, want %d", tt.issue.Number, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package spam
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+func GetPullByNumber(owner, repo string, number int) (Issue, error) {
+	return getByNumber(owner, repo, "pullRequest", pullDetailFields, number)
+}
+
+func GetDiscussionByNumber(owner, repo string, number int) (Issue, error) {
+	return getByNumber(owner, repo, "discussion", discussionFields, number)
+}
+
+// GetItemByNumber gets an issue, pull request or discussion
+func GetItemByNumber(kind, owner, repo string, number int) (Issue, error) {
+	switch kind {
+	case KindPR:
+		return GetPullByNumber(owner, repo, number)
+	case KindDiscussion:
+		return GetDiscussionByNumber(owner, repo, number)
+	case KindComment:
+		return Issue{}, fmt.Errorf("Comments are looked up by URL, not number")
+	default:
+		return GetIssueByNumber(owner, repo, number)
+	}
+}
+
+// Finds pull requests that were likely closed as spam
+func GetSpamPulls(owner, repo string, limit int) ([]Issue, error) {
+	q := fmt.Sprintf("repo:%s/%s is:pr is:closed is:unmerged comments:0", owner, repo)
+	return searchQuery(q, "ISSUE", pullDetailFields, limit)
+}
+
+// Gets merged pull requests, which are not spam
+func GetNonSpamPulls(owner, repo string, limit int) ([]Issue, error) {
+	q := fmt.Sprintf("repo:%s/%s is:pr is:merged", owner, repo)
+	return searchQuery(q, "ISSUE", pullDetailFields, limit)
+}
+
+// Finds discussions that were likely closed as spam
+func GetSpamDiscussions(owner, repo string, limit int) ([]Issue, error) {
+	q := fmt.Sprintf("repo:%s/%s is:closed comments:0", owner, repo)
+	return searchQuery(q, "DISCUSSION", discussionFields, limit)
+}
+
+// Gets answered discussions, which are not spam
+func GetNonSpamDiscussions(owner, repo string, limit int) ([]Issue, error) {
+	q := fmt.Sprintf("repo:%s/%s is:answered", owner, repo)
+	return searchQuery(q, "DISCUSSION", discussionFields, limit)
+}
+
+// Gets items of a kind created since a time, newest first
+func GetRecentItems(kind, owner, repo string, since time.Time, limit int) ([]Issue, error) {
+	created := since.UTC().Format(time.RFC3339)
+	switch kind {
+	case KindPR:
+		q := fmt.Sprintf("repo:%s/%s is:pr created:>=%s sort:created-desc", owner, repo, created)
+		return recentSearchQuery(q, "ISSUE", pullDetailFields, limit)
+	case KindDiscussion:
+		q := fmt.Sprintf("repo:%s/%s created:>=%s sort:created-desc", owner, repo, created)
+		return recentSearchQuery(q, "DISCUSSION", discussionFields, limit)
+	case KindComment:
+		return GetRecentComments(owner, repo, since, limit)
+	default:
+		return GetRecentIssues(owner, repo, since, limit)
+	}
+}
+
+// Gets items of a kind created between two days, newest first. The times
+// are rounded to days, so repeated searches can be cached.
+func GetItemsCreated(kind, owner, repo string, from, to time.Time, limit int) ([]Issue, error) {
+	created := fmt.Sprintf("%s..%s", from.UTC().Format("2006-01-02"), to.UTC().Format("2006-01-02"))
+	switch kind {
+	case KindPR:
+		q := fmt.Sprintf("repo:%s/%s is:pr created:%s sort:created-desc", owner, repo, created)
+		return searchQuery(q, "ISSUE", pullDetailFields, limit)
+	case KindDiscussion:
+		q := fmt.Sprintf("repo:%s/%s created:%s sort:created-desc", owner, repo, created)
+		return searchQuery(q, "DISCUSSION", discussionFields, limit)
+	case KindComment:
+		comments, err := GetRecentComments(owner, repo, from, limit)
+		if err != nil {
+			return nil, err
+		}
+		end := to.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		inRange := []Issue{}
+		for _, comment := range comments {
+			if t, err := time.Parse(time.RFC3339, comment.CreatedAt); err != nil || t.Before(end) {
+				inRange = append(inRange, comment)
+			}
+		}
+		return inRange, nil
+	default:
+		q := fmt.Sprintf("repo:%s/%s is:issue created:%s sort:created-desc", owner, repo, created)
+		return issueSearchQuery(q, limit)
+	}
+}
+
+// Gets open items of a kind created since a time, newest first.
+// For comments, gets all comments created since the time.
+func GetOpenItems(kind, owner, repo string, since time.Time, limit int) ([]Issue, error) {
+	created := since.UTC().Format(time.RFC3339)
+	switch kind {
+	case KindPR:
+		q := fmt.Sprintf("repo:%s/%s is:pr is:open created:>=%s sort:created-desc", owner, repo, created)
+		return recentSearchQuery(q, "ISSUE", pullDetailFields, limit)
+	case KindDiscussion:
+		q := fmt.Sprintf("repo:%s/%s is:open created:>=%s sort:created-desc", owner, repo, created)
+		return recentSearchQuery(q, "DISCUSSION", discussionFields, limit)
+	case KindComment:
+		return GetRecentComments(owner, repo, since, limit)
+	default:
+		return GetOpenIssues(owner, repo, since, limit)
+	}
+}
+
+// Gets the repo's pull request templates, preprocessed for similarity scoring
+func GetPullTemplates(owner, repo string) ([]Template, error) {
+	query := `query GetPullTemplates($owner: String!, $repo: String!) {
+  	repository(owner: $owner, name: $repo) { pullRequestTemplates { body } } }`
+
+	variables := map[string]interface{}{"owner": owner, "repo": repo}
+	resp := struct {
+		Repository struct {
+			PullRequestTemplates []struct{ Body string }
+		}
+	}{}
+
+	client, err := gqlClient(true)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Do(query, variables, &resp); err != nil {
+		return nil, err
+	}
+
+	bodies := []string{}
+	for _, body := range resp.Repository.PullRequestTemplates {
+		bodies = append(bodies, body.Body)
+	}
+	return NewTemplates(bodies), nil
+}
+
+// GetTemplatesFor gets the templates to compare items of a kind against
+func GetTemplatesFor(kind, owner, repo string) ([]Template, error) {
+	switch kind {
+	case KindPR:
+		return GetPullTemplates(owner, repo)
+	case KindDiscussion, KindComment:
+		return nil, nil
+	default:
+		return GetTemplates(owner, repo)
+	}
+}
+
+var docExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".rst":      true,
+	".txt":      true,
+	".adoc":     true,
+}
+
+// DocsOnly reports whether all the files are documentation
+func DocsOnly(files []string) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		lower := strings.ToLower(file)
+		base := path.Base(lower)
+		isDoc := docExts[path.Ext(lower)] ||
+			strings.HasPrefix(lower, "docs/") || strings.Contains(lower, "/docs/") ||
+			strings.HasPrefix(base, "readme") || strings.HasPrefix(base, "license")
+		if !isDoc {
+			return false
+		}
+	}
+	return true
+}
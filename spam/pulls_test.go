@@ -0,0 +1,24 @@
+package spam
+
+import "testing"
+
+func TestDocsOnly(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  bool
+	}{
+		{"docs", []string{"README.md", "docs/install.txt", "pkg/docs/usage.go", "LICENSE"}, true},
+		{"case", []string{"Readme", "CHANGES.MD"}, true},
+		{"mixed", []string{"README.md", "main.go"}, false},
+		{"code", []string{"main.go"}, false},
+		{"docs in the name", []string{"docsite/main.go"}, false},
+		{"empty", []string{}, false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := DocsOnly(tt.files); got != tt.want {
+			t.Errorf("%s: DocsOnly(%q) = %v, want %v", tt.name, tt.files, got, tt.want)
+		}
+	}
+}
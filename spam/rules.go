@@ -0,0 +1,279 @@
+package spam
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Rule is a deterministic check that runs before the model.
+// All of a rule's conditions must match for it to fire.
+type Rule struct {
+	Name string `yaml:"name"`
+
+	// Users and Orgs match the author's login or organization membership
+	Users []string `yaml:"users"`
+	Orgs  []string `yaml:"orgs"`
+
+	// Associations match the author's association with the repo, e.g. OWNER
+	Associations []string `yaml:"associations"`
+
+	// Title and Body are regular expressions
+	Title string `yaml:"title"`
+	Body  string `yaml:"body"`
+
+	// Keywords match if any appears in the title or body, ignoring case
+	Keywords []string `yaml:"keywords"`
+
+	// LinkedPR matches whether the issue has a linked pull request
+	LinkedPR *bool `yaml:"linked_pr"`
+
+	// Verdict short-circuits the model with "spam" or "not spam".
+	// Otherwise Adjust is added to the model's spam probability.
+	Verdict string  `yaml:"verdict"`
+	Adjust  float64 `yaml:"adjust"`
+
+	title *regexp.Regexp
+	body  *regexp.Regexp
+	users map[string]bool
+}
+
+// RuleResult is the outcome of running the rules on an issue
+type RuleResult struct {
+	// Verdict is set if a rule short-circuited the model
+	Verdict string
+
+	// Adjust is the sum of the fired rules' score adjustments
+	Adjust float64
+
+	// Fired are the names of the rules that matched
+	Fired []string
+}
+
+// RuleSet is a compiled list of rules
+type RuleSet struct {
+	rules []Rule
+
+	// mu guards members, so rules can be evaluated concurrently
+	mu      sync.Mutex
+	members map[string]bool
+
+	// lookupMember checks org membership. Tests replace it.
+	lookupMember func(org, login string) (bool, error)
+}
+
+// contributorRule replaces the old assumption that contributors never post spam
+var contributorRule = Rule{
+	Name:         "contributor",
+	Associations: []string{"CONTRIBUTOR", "MEMBER", "OWNER"},
+	Verdict:      "not spam",
+}
+
+// builtinRules run after the allow, block and keyword lists unless the
+// policy disables them or has a rule of the same name
+var builtinRules = []Rule{contributorRule}
+
+// CompileRules builds the policy's rules, with the allow, block and keyword
+// lists ahead of the custom rules. Teams are resolved to their members.
+func (c Config) CompileRules() (*RuleSet, error) {
+	rules := []Rule{}
+
+	allowed := append([]string{}, c.Allow.Users...)
+	for _, team := range c.Allow.Teams {
+		orgTeam := strings.Split(team, "/")
+		if len(orgTeam) != 2 {
+			return nil, fmt.Errorf("Invalid team %q, expected ORG/TEAM", team)
+		}
+		members, err := GetTeamMembers(orgTeam[0], orgTeam[1])
+		if err != nil {
+			return nil, fmt.Errorf("Error getting members of %s: %s", team, err)
+		}
+		allowed = append(allowed, members...)
+	}
+	if len(allowed) > 0 {
+		rules = append(rules, Rule{Name: "allowed-user", Users: allowed, Verdict: "not spam"})
+	}
+	if len(c.Allow.Orgs) > 0 {
+		rules = append(rules, Rule{Name: "allowed-org", Orgs: c.Allow.Orgs, Verdict: "not spam"})
+	}
+	if len(c.Block.Users) > 0 {
+		rules = append(rules, Rule{Name: "blocked-user", Users: c.Block.Users, Verdict: "spam"})
+	}
+	if len(c.Keywords) > 0 {
+		rules = append(rules, Rule{Name: "keyword", Keywords: c.Keywords, Verdict: "spam"})
+	}
+
+	skip := map[string]bool{}
+	for _, name := range c.DisableRules {
+		known := false
+		for _, rule := range builtinRules {
+			known = known || rule.Name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown built-in rule %q in disable_rules", name)
+		}
+		skip[name] = true
+	}
+	for _, rule := range c.Rules {
+		skip[rule.Name] = true
+	}
+	for _, rule := range builtinRules {
+		if !skip[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+
+	for i, rule := range c.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		rules = append(rules, rule)
+	}
+
+	set := &RuleSet{members: map[string]bool{}, lookupMember: IsOrgMember}
+	for _, rule := range rules {
+		if rule.Verdict != "" && rule.Verdict != "spam" && rule.Verdict != "not spam" {
+			return nil, fmt.Errorf("Invalid verdict %q in rule %s", rule.Verdict, rule.Name)
+		}
+
+		var err error
+		if rule.Title != "" {
+			if rule.title, err = regexp.Compile(rule.Title); err != nil {
+				return nil, fmt.Errorf("Invalid title regex in rule %s: %s", rule.Name, err)
+			}
+		}
+		if rule.Body != "" {
+			if rule.body, err = regexp.Compile(rule.Body); err != nil {
+				return nil, fmt.Errorf("Invalid body regex in rule %s: %s", rule.Name, err)
+			}
+		}
+		if len(rule.Users) > 0 {
+			rule.users = map[string]bool{}
+			for _, user := range rule.Users {
+				rule.users[strings.ToLower(user)] = true
+			}
+		}
+		set.rules = append(set.rules, rule)
+	}
+	return set, nil
+}
+
+// Evaluate runs the rules in order. The first rule with a verdict stops
+// evaluation; rules without one accumulate their score adjustments.
+func (s *RuleSet) Evaluate(issue Issue) (RuleResult, error) {
+	res := RuleResult{}
+	for _, rule := range s.rules {
+		ok, err := s.matches(rule, issue)
+		if err != nil {
+			return res, fmt.Errorf("Error evaluating rule %s: %s", rule.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		res.Fired = append(res.Fired, rule.Name)
+		if rule.Verdict != "" {
+			res.Verdict = rule.Verdict
+			return res, nil
+		}
+		res.Adjust += rule.Adjust
+	}
+	return res, nil
+}
+
+// Score applies the rules' outcome to the model's spam probability
+func (r RuleResult) Score(prob float64) float64 {
+	switch r.Verdict {
+	case "spam":
+		return 1
+	case "not spam":
+		return 0
+	}
+
+	prob += r.Adjust
+	if prob < 0 {
+		return 0
+	}
+	if prob > 1 {
+		return 1
+	}
+	return prob
+}
+
+func (s *RuleSet) matches(rule Rule, issue Issue) (bool, error) {
+	login := strings.ToLower(issue.Author.Login)
+	if rule.users != nil && !rule.users[login] {
+		return false, nil
+	}
+	if len(rule.Associations) > 0 && !containsFold(rule.Associations, issue.AuthorAssociation) {
+		return false, nil
+	}
+	if rule.title != nil && !rule.title.MatchString(issue.Title) {
+		return false, nil
+	}
+	if rule.body != nil && !rule.body.MatchString(issue.Body) {
+		return false, nil
+	}
+	if len(rule.Keywords) > 0 && !hasKeyword(issue, rule.Keywords) {
+		return false, nil
+	}
+	if rule.LinkedPR != nil && *rule.LinkedPR != (issue.LinkedPRs > 0) {
+		return false, nil
+	}
+	if len(rule.Orgs) > 0 {
+		member := false
+		for _, org := range rule.Orgs {
+			ok, err := s.isOrgMember(org, login)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				member = true
+				break
+			}
+		}
+		if !member {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isOrgMember caches org membership lookups for the rule set. Failed
+// lookups aren't cached.
+func (s *RuleSet) isOrgMember(org, login string) (bool, error) {
+	key := strings.ToLower(org) + "/" + login
+	s.mu.Lock()
+	member, ok := s.members[key]
+	s.mu.Unlock()
+	if ok {
+		return member, nil
+	}
+	member, err := s.lookupMember(org, login)
+	if err != nil {
+		return false, fmt.Errorf("Error checking %s membership of %s: %s", org, login, err)
+	}
+	s.mu.Lock()
+	s.members[key] = member
+	s.mu.Unlock()
+	return member, nil
+}
+
+func hasKeyword(issue Issue, keywords []string) bool {
+	text := strings.ToLower(issue.Title + "\n" + issue.Body)
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package spam
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func ruleIssue(login, association, title string) Issue {
+	issue := Issue{Title: title, AuthorAssociation: association}
+	issue.Author.Login = login
+	return issue
+}
+
+func TestEvaluate(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+allow:
+  users: [octocat]
+block:
+  users: [spammer]
+keywords: [casino]
+rules:
+  - name: link-drop
+    title: 'https?://'
+    adjust: 0.2
+  - name: shouting
+    title: '^[A-Z !]+$'
+    adjust: 0.3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := cfg.CompileRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		issue   Issue
+		verdict string
+		fired   []string
+		score   float64
+	}{
+		{ruleIssue("octocat", "NONE", "casino"), "not spam", []string{"allowed-user"}, 0},
+		{ruleIssue("spammer", "OWNER", "hi"), "spam", []string{"blocked-user"}, 1},
+		{ruleIssue("someone", "NONE", "Best casino"), "spam", []string{"keyword"}, 1},
+		{ruleIssue("someone", "CONTRIBUTOR", "see http://x.y"), "not spam", []string{"contributor"}, 0},
+		{ruleIssue("someone", "NONE", "see http://x.y"), "", []string{"link-drop"}, 0.6},
+		{ruleIssue("someone", "NONE", "BUY NOW"), "", []string{"shouting"}, 0.7},
+		{ruleIssue("someone", "NONE", "crash on start"), "", nil, 0.4},
+	}
+	for _, tt := range tests {
+		res, err := rules.Evaluate(tt.issue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Verdict != tt.verdict || !reflect.DeepEqual(res.Fired, tt.fired) {
+			t.Errorf("%q by %s: got %q %v, want %q %v", tt.issue.Title, tt.issue.Author.Login, res.Verdict, res.Fired, tt.verdict, tt.fired)
+		}
+		if score := res.Score(0.4); score < tt.score-1e-9 || score > tt.score+1e-9 {
+			t.Errorf("%q: got score %v, want %v", tt.issue.Title, score, tt.score)
+		}
+	}
+}
+
+func TestBuiltinRules(t *testing.T) {
+	contributor := ruleIssue("someone", "MEMBER", "hi")
+	tests := []struct {
+		yaml    string
+		verdict string
+	}{
+		{"", "not spam"},
+		{"rules: [{name: other, title: nomatch, adjust: 0.1}]", "not spam"},
+		{"disable_rules: [contributor]", ""},
+		{"rules: [{name: contributor, associations: [OWNER], verdict: not spam}]", ""},
+	}
+	for _, tt := range tests {
+		cfg, err := ParseConfig([]byte(tt.yaml))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules, err := cfg.CompileRules()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := rules.Evaluate(contributor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Verdict != tt.verdict {
+			t.Errorf("%q: got verdict %q for a member, want %q", tt.yaml, res.Verdict, tt.verdict)
+		}
+	}
+
+	cfg, _ := ParseConfig([]byte("disable_rules: [nope]"))
+	if _, err := cfg.CompileRules(); err == nil {
+		t.Error("expected an error disabling an unknown rule")
+	}
+}
+
+func TestOrgRule(t *testing.T) {
+	cfg, err := ParseConfig([]byte("allow: {orgs: [cli]}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := cfg.CompileRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookups := 0
+	rules.lookupMember = func(org, login string) (bool, error) {
+		lookups++
+		if login == "broken" {
+			return false, errors.New("HTTP 502")
+		}
+		return login == "mislav", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		res, err := rules.Evaluate(ruleIssue("mislav", "NONE", "hi"))
+		if err != nil || res.Verdict != "not spam" {
+			t.Errorf("got %q (%v) for an org member, want not spam", res.Verdict, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("got %d lookups, want membership cached", lookups)
+	}
+	if res, err := rules.Evaluate(ruleIssue("other", "NONE", "hi")); err != nil || res.Verdict != "" {
+		t.Errorf("got %q (%v) for a non-member, want no verdict", res.Verdict, err)
+	}
+	if _, err := rules.Evaluate(ruleIssue("broken", "NONE", "hi")); err == nil {
+		t.Error("expected a failed membership lookup to be an error")
+	}
+}